@@ -0,0 +1,370 @@
+package sky
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// BackupFormat is the version of the archive format written by BackupTable.
+const BackupFormat = 1
+
+// RestoreFlushInterval is the number of events sent between flushes of the
+// restore stream. A failed restore can be resumed from the last flush.
+var RestoreFlushInterval = 1000
+
+// backupRecord is a single line of a backup archive. The archive starts with
+// a header, followed by the table's properties and events, and ends with a
+// footer holding the record counts and a checksum of all preceding lines.
+type backupRecord struct {
+	Type string `json:"type"`
+
+	// Header fields.
+	Format    int    `json:"format,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Table     string `json:"table,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+
+	// Property and event fields.
+	Property *Property              `json:"property,omitempty"`
+	ID       string                 `json:"id,omitempty"`
+	Event    map[string]interface{} `json:"event,omitempty"`
+
+	// Footer fields.
+	Properties int    `json:"properties,omitempty"`
+	Objects    int    `json:"objects,omitempty"`
+	Events     int    `json:"events,omitempty"`
+	Checksum   string `json:"checksum,omitempty"`
+}
+
+// RestoreError is returned when a restore fails after its table is created.
+// Events is the number of events that were flushed to the server and can be
+// passed to ResumeRestoreTable to continue the restore.
+type RestoreError struct {
+	Events int
+	Err    error
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("restore failed after %d events: %s", e.Events, e.Err)
+}
+
+// BackupTable writes the table's properties and the events of every object to
+// w as a newline delimited JSON archive.
+func (c *Client) BackupTable(name string, w io.Writer) error {
+	t, err := c.Table(name)
+	if err != nil {
+		return err
+	}
+	t.Name = name
+	properties, err := t.Properties()
+	if err != nil {
+		return err
+	}
+	keys, err := t.Keys()
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(w, h))
+	header := &backupRecord{Type: "header", Format: BackupFormat, Version: Version, Table: name, CreatedAt: FormatTimestamp(time.Now())}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, p := range properties {
+		if err := enc.Encode(&backupRecord{Type: "property", Property: p}); err != nil {
+			return err
+		}
+	}
+	footer := &backupRecord{Type: "footer", Properties: len(properties)}
+	for _, id := range keys {
+		events, err := t.Events(id)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := enc.Encode(&backupRecord{Type: "event", ID: id, Event: e.Serialize()}); err != nil {
+				return err
+			}
+		}
+		if len(events) > 0 {
+			footer.Objects++
+		}
+		footer.Events += len(events)
+	}
+	footer.Checksum = hex.EncodeToString(h.Sum(nil))
+	return json.NewEncoder(w).Encode(footer)
+}
+
+// RestoreTable creates a table from an archive written by BackupTable. If
+// newName is blank then the original table name is used. The archive is read
+// twice: once to verify its record counts and checksum and again to replay
+// its events through a stream. Archives that cannot seek are copied to a
+// temporary file for the second pass.
+func (c *Client) RestoreTable(r io.Reader, newName string) error {
+	return c.restoreTable(r, newName, 0, false)
+}
+
+// ResumeRestoreTable continues a restore that failed with a *RestoreError. The
+// first offset events are skipped. The table and any missing properties are
+// created if they do not exist yet.
+func (c *Client) ResumeRestoreTable(r io.Reader, newName string, offset int) error {
+	return c.restoreTable(r, newName, offset, true)
+}
+
+func (c *Client) restoreTable(r io.Reader, newName string, offset int, resume bool) error {
+	archive, cleanup, err := verifyBackup(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	br := bufio.NewReader(archive)
+	header, err := readBackupRecord(br, nil)
+	if err != nil {
+		return err
+	}
+	name := newName
+	if name == "" {
+		name = header.Table
+	}
+	t, err := c.restoreTarget(name, resume)
+	if err != nil {
+		return err
+	}
+	t.Name = name
+
+	// Failures after the table exists can be resumed.
+	restore := &restore{table: t, offset: offset, flushed: offset}
+	existing, err := t.Properties()
+	if err != nil {
+		return restore.fail(err)
+	}
+	exists := map[string]bool{}
+	for _, p := range existing {
+		exists[p.Name] = true
+	}
+
+	for {
+		record, err := readBackupRecord(br, nil)
+		if err != nil {
+			return restore.fail(err)
+		}
+		switch record.Type {
+		case "property":
+			if exists[record.Property.Name] {
+				continue
+			}
+			if err := t.CreateProperty(record.Property); err != nil {
+				return restore.fail(err)
+			}
+			exists[record.Property.Name] = true
+
+		case "event":
+			e := &Event{}
+			if err := e.Deserialize(record.Event); err != nil {
+				return restore.fail(err)
+			}
+			if err := restore.insert(record.ID, e); err != nil {
+				return restore.fail(err)
+			}
+
+		case "footer":
+			return restore.close()
+		}
+	}
+}
+
+// restoreTarget creates the table for a restore. A resumed restore uses the
+// table if it already exists.
+func (c *Client) restoreTarget(name string, resume bool) (*Table, error) {
+	if resume {
+		t, err := c.Table(name)
+		if err == nil {
+			return t, nil
+		} else if err, ok := err.(*APIError); !ok || err.StatusCode != http.StatusNotFound {
+			return nil, err
+		}
+	}
+	t := &Table{Name: name}
+	if err := c.CreateTable(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// verifyBackup reads a whole archive and checks its structure, record counts
+// and checksum. It returns a reader positioned at the start of the archive and
+// a function to release any temporary file.
+func verifyBackup(r io.Reader) (io.Reader, func(), error) {
+	cleanup := func() {}
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		f, err := os.CreateTemp("", "sky-restore-")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			cleanup()
+			return nil, nil, err
+		} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		rs = f
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err == nil {
+		err = checkBackup(rs)
+	}
+	if err == nil {
+		_, err = rs.Seek(start, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return rs, cleanup, nil
+}
+
+// checkBackup reads an archive and compares it with its footer.
+func checkBackup(r io.Reader) error {
+	br := bufio.NewReader(r)
+	h := sha256.New()
+	header, err := readBackupRecord(br, h)
+	if err != nil {
+		return err
+	} else if header.Type != "header" || header.Format != BackupFormat {
+		return ErrInvalidBackup
+	}
+
+	var properties, events int
+	objects := map[string]bool{}
+	for {
+		record, err := readBackupRecord(br, h)
+		if err != nil {
+			return err
+		}
+		switch record.Type {
+		case "property":
+			if record.Property == nil || record.Property.Name == "" {
+				return ErrInvalidBackup
+			}
+			properties++
+		case "event":
+			if record.ID == "" {
+				return ErrInvalidBackup
+			}
+			objects[record.ID] = true
+			events++
+		case "footer":
+			if record.Checksum != record.sum || record.Properties != properties || record.Objects != len(objects) || record.Events != events {
+				return ErrBackupChecksum
+			}
+			return nil
+		default:
+			return ErrInvalidBackup
+		}
+	}
+}
+
+// restore tracks the events sent to a table during a restore.
+type restore struct {
+	table   *Table
+	stream  *TableEventStream
+	offset  int
+	n       int
+	flushed int
+}
+
+// insert sends an event to the stream unless it was sent by an earlier restore.
+func (r *restore) insert(id string, e *Event) error {
+	if r.n < r.offset {
+		r.n++
+		return nil
+	}
+	if r.stream == nil {
+		stream, err := r.table.Stream()
+		if err != nil {
+			return err
+		}
+		r.stream = stream
+	}
+	if err := r.stream.InsertEvent(id, e); err != nil {
+		return err
+	}
+	r.n++
+	if (r.n-r.offset)%RestoreFlushInterval == 0 {
+		if err := r.stream.Flush(); err != nil {
+			return err
+		}
+		r.flushed = r.n
+	}
+	return nil
+}
+
+// close flushes and closes the stream.
+func (r *restore) close() error {
+	if r.stream == nil {
+		return nil
+	}
+	if err := r.stream.Close(); err != nil {
+		return &RestoreError{Events: r.flushed, Err: err}
+	}
+	return nil
+}
+
+// fail closes any stream and returns err as a *RestoreError.
+func (r *restore) fail(err error) error {
+	if r.stream != nil {
+		if cerr := r.stream.Close(); cerr == nil {
+			r.flushed = r.n
+		}
+	}
+	return &RestoreError{Events: r.flushed, Err: err}
+}
+
+// checksummedRecord is a backup record with the checksum of all lines read
+// before it.
+type checksummedRecord struct {
+	*backupRecord
+	sum string
+}
+
+// readBackupRecord reads the next line of an archive. Lines other than the
+// footer are added to the checksum if h is not nil.
+func readBackupRecord(r *bufio.Reader, h hash.Hash) (*checksummedRecord, error) {
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return nil, ErrInvalidBackup
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+	record := &backupRecord{}
+	if err := json.Unmarshal(line, record); err != nil {
+		return nil, ErrInvalidBackup
+	}
+	if h == nil {
+		return &checksummedRecord{record, ""}, nil
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if record.Type != "footer" {
+		h.Write(line)
+	}
+	return &checksummedRecord{record, sum}, nil
+}
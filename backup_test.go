@@ -0,0 +1,130 @@
+package sky
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newBackupServer returns a fake server with a table to back up.
+func newBackupServer(t *testing.T) (*fakeServer, *Client) {
+	s, c := newFakeServer(t)
+	table := s.table("t0", &Property{Name: "gender", DataType: Factor}, &Property{Name: "price", Transient: true, DataType: Float})
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	table.insert("o1", &Event{Timestamp: t0, Data: map[string]interface{}{"gender": "f"}})
+	table.insert("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"gender": "m"}})
+	table.insert("o0", &Event{Timestamp: t0.Add(time.Second), Data: map[string]interface{}{"price": 10.5}})
+	return s, c
+}
+
+// Ensure that a table can be backed up and restored under a new name.
+func TestClientBackupRestoreTable(t *testing.T) {
+	s, c := newBackupServer(t)
+	defer s.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.BackupTable("t0", &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, len(lines), 7)
+	assert.Contains(t, lines[0], `"type":"header"`)
+	assert.Contains(t, lines[6], `"objects":2,"events":3`)
+
+	assert.NoError(t, c.RestoreTable(bytes.NewReader(buf.Bytes()), "t1"))
+	s.waitStreams(1)
+	assert.Equal(t, s.tables["t1"].properties, s.tables["t0"].properties)
+	assert.Equal(t, s.tables["t1"].objects, s.tables["t0"].objects)
+
+	// Restoring over an existing table fails before sending events.
+	err := c.RestoreTable(bytes.NewReader(buf.Bytes()), "t1")
+	assert.IsType(t, &APIError{}, err)
+}
+
+// Ensure that a restore can be resumed from an event offset.
+func TestClientResumeRestoreTable(t *testing.T) {
+	s, c := newBackupServer(t)
+	defer s.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.BackupTable("t0", &buf))
+	s.table("t1", &Property{Name: "gender", DataType: Factor})
+
+	assert.NoError(t, c.ResumeRestoreTable(bytes.NewReader(buf.Bytes()), "t1", 2))
+	s.waitStreams(1)
+	assert.Equal(t, len(s.tables["t1"].properties), 2)
+	assert.Equal(t, len(s.tables["t1"].objects), 1)
+	assert.Equal(t, s.tables["t1"].objects["o1"], s.tables["t0"].objects["o1"])
+
+	// Resuming creates the table if it does not exist.
+	assert.NoError(t, c.ResumeRestoreTable(bytes.NewReader(buf.Bytes()), "t2", 0))
+	s.waitStreams(2)
+	assert.Equal(t, s.tables["t2"].objects, s.tables["t0"].objects)
+}
+
+// Ensure that a restore that fails while streaming reports the events that
+// reached the server and can be resumed from there.
+func TestClientRestoreTableStreamError(t *testing.T) {
+	s, c := newBackupServer(t)
+	defer s.Close()
+	defer func(n int) { RestoreFlushInterval = n }(RestoreFlushInterval)
+	RestoreFlushInterval = 1
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.BackupTable("t0", &buf))
+	archive := buf.String()
+
+	// Corrupt the last event and recompute the checksum so the archive
+	// verifies but fails while it is replayed.
+	lines := strings.SplitAfter(archive, "\n")
+	lines[5] = strings.Replace(lines[5], `"1970-01-01T00:00:00Z"`, `"bad"`, 1)
+	footer := &backupRecord{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[6]), footer))
+	sum := sha256.Sum256([]byte(strings.Join(lines[:6], "")))
+	footer.Checksum = hex.EncodeToString(sum[:])
+	b, _ := json.Marshal(footer)
+	lines[6] = string(b) + "\n"
+
+	err := c.RestoreTable(strings.NewReader(strings.Join(lines, "")), "t1")
+	if err, ok := err.(*RestoreError); assert.True(t, ok) {
+		assert.Equal(t, err.Events, 2)
+	}
+	s.waitStreams(1)
+	assert.Equal(t, len(s.tables["t1"].objects), 1)
+	assert.Equal(t, s.tables["t1"].objects["o0"], s.tables["t0"].objects["o0"])
+
+	assert.NoError(t, c.ResumeRestoreTable(strings.NewReader(archive), "t1", 2))
+	s.waitStreams(2)
+	assert.Equal(t, s.tables["t1"].objects, s.tables["t0"].objects)
+}
+
+// Ensure that corrupt archives are rejected before anything is restored.
+func TestClientRestoreTableInvalid(t *testing.T) {
+	s, c := newBackupServer(t)
+	defer s.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.BackupTable("t0", &buf))
+	archive := buf.String()
+
+	// Modified events fail the checksum.
+	err := c.RestoreTable(strings.NewReader(strings.Replace(archive, `"f"`, `"x"`, 1)), "t1")
+	assert.Equal(t, err, ErrBackupChecksum)
+
+	// Archives with missing records fail the counts.
+	lines := strings.SplitAfter(archive, "\n")
+	err = c.RestoreTable(strings.NewReader(strings.Join(append(lines[:5:5], lines[6]), "")), "t1")
+	assert.Equal(t, err, ErrBackupChecksum)
+
+	// Truncated archives are invalid.
+	err = c.RestoreTable(strings.NewReader(archive[:strings.LastIndex(archive, `{"type":"footer"`)]), "t1")
+	assert.Equal(t, err, ErrInvalidBackup)
+
+	// Archives without a header are invalid.
+	assert.Equal(t, c.RestoreTable(strings.NewReader("{}\n"), "t1"), ErrInvalidBackup)
+	assert.Nil(t, s.tables["t1"])
+}
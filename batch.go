@@ -0,0 +1,223 @@
+package sky
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultBatchSize is the maximum number of bytes sent in a single batch
+	// request if no size is set on the client.
+	DefaultBatchSize = 1 << 20
+
+	// DefaultBatchConcurrency is the number of batch requests sent in
+	// parallel if no concurrency is set on the client.
+	DefaultBatchConcurrency = 4
+)
+
+// ObjectEvent pairs an event with the identifier of the object it belongs to.
+type ObjectEvent struct {
+	ID    string
+	Event *Event
+}
+
+// BatchError is the error for a single item in a batch insert.
+type BatchError struct {
+	Index int
+	ID    string
+	Event *Event
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("item %d (%s): %v", e.Index, e.ID, e.Err)
+}
+
+// BatchErrors is returned from a batch insert when one or more items fail.
+type BatchErrors []*BatchError
+
+func (e BatchErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d items failed; first error: %v", len(e), e[0])
+}
+
+// InsertEvents adds multiple events to a single object in as few requests
+// as possible.
+func (t *Table) InsertEvents(id string, events []*Event) error {
+	items := make([]ObjectEvent, len(events))
+	for i, e := range events {
+		items[i] = ObjectEvent{ID: id, Event: e}
+	}
+	return t.InsertBatch(items)
+}
+
+// InsertBatch adds events to multiple objects. Items are split into requests
+// of at most the client's BatchSize bytes which are sent concurrently. If any
+// items fail then a BatchErrors is returned with an error for each one. When
+// the server rejects a request it is split in half and each half is sent
+// again until the rejected items are found. Other request errors are
+// reported for every item in the request.
+// Items rejected by the server are also sent to the client's dead letter sink
+// and recently inserted duplicates, including duplicates within items, are
+// skipped if the client has a deduper.
+// Servers without bulk event support are sent one request per item.
+func (t *Table) InsertBatch(items []ObjectEvent) error {
+	if t.Client == nil {
+		return ErrClientRequired
+	} else if !t.Client.supports(CapabilityBulkEvents) {
+		return t.insertEach(items)
+	}
+	var errs BatchErrors
+	var mutex sync.Mutex
+	fail := func(indices []int, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, index := range indices {
+			item := items[index]
+			errs = append(errs, &BatchError{Index: index, ID: item.ID, Event: item.Event, Err: err})
+			if err, ok := rejection(err); ok {
+				t.Client.deadLetter(t.Name, item.ID, item.Event, err)
+			}
+		}
+	}
+
+	// Split items into batches and send them to a pool of workers.
+	batches := make(chan *batch)
+	var wg sync.WaitGroup
+	for i := 0; i < t.Client.batchConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				t.sendBatch(b, fail)
+			}
+		}()
+	}
+
+	var sent []int
+	b := &batch{}
+	pending := t.Client.Deduper.pending(len(items))
+	for i, item := range items {
+		line, err := encodeObjectEvent(item)
+		if err != nil {
+			fail([]int{i}, err)
+			continue
+		} else if t.Client.Deduper.Duplicate(t.Name, item.ID, item.Event) || pending.Duplicate(t.Name, item.ID, item.Event) {
+			continue
+		}
+		pending.Remember(t.Name, item.ID, item.Event)
+		if b.size > 0 && b.size+len(line) > t.Client.batchSize() {
+			batches <- b
+			b = &batch{}
+		}
+		b.add(i, line)
+		sent = append(sent, i)
+	}
+	if b.size > 0 {
+		batches <- b
+	}
+	close(batches)
+	wg.Wait()
+
+	// Remember the items that were accepted.
+	failed := map[int]bool{}
+	for _, err := range errs {
+		failed[err.Index] = true
+	}
+	for _, index := range sent {
+		if !failed[index] {
+			t.Client.Deduper.Remember(t.Name, items[index].ID, items[index].Event)
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+		return errs
+	}
+	return nil
+}
+
+// insertEach inserts items one at a time for servers without bulk support.
+func (t *Table) insertEach(items []ObjectEvent) error {
+	var errs BatchErrors
+	for i, item := range items {
+		if err := t.InsertEvent(item.ID, item.Event); err != nil {
+			errs = append(errs, &BatchError{Index: i, ID: item.ID, Event: item.Event, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// sendBatch sends a batch in a single request. Rejected batches are bisected
+// so that only the rejected items fail. Items accepted before a rejection are
+// sent again, which is safe since inserts replace events by timestamp.
+func (t *Table) sendBatch(b *batch, fail func(indices []int, err error)) {
+	err := t.Client.send(context.Background(), "PATCH", fmt.Sprintf("/tables/%s/events", t.Name), nil, "application/json", b.body(), nil)
+	if err == nil {
+		return
+	} else if _, ok := rejection(err); !ok || len(b.indices) == 1 {
+		fail(b.indices, err)
+		return
+	}
+	mid := len(b.indices) / 2
+	t.sendBatch(&batch{lines: b.lines[:mid], indices: b.indices[:mid]}, fail)
+	t.sendBatch(&batch{lines: b.lines[mid:], indices: b.indices[mid:]}, fail)
+}
+
+// batch is a set of encoded items sent in a single request.
+type batch struct {
+	lines   [][]byte
+	indices []int
+	size    int
+}
+
+// add appends an encoded item to the batch.
+func (b *batch) add(index int, line []byte) {
+	b.lines = append(b.lines, line)
+	b.indices = append(b.indices, index)
+	b.size += len(line)
+}
+
+// body returns the request body for the batch.
+func (b *batch) body() []byte {
+	return bytes.Join(b.lines, nil)
+}
+
+// encodeObjectEvent encodes an item as a single line in a batch request.
+func encodeObjectEvent(item ObjectEvent) ([]byte, error) {
+	if item.ID == "" {
+		return nil, ErrIDRequired
+	} else if item.Event == nil {
+		return nil, ErrEventRequired
+	}
+	data := item.Event.Serialize()
+	data["id"] = item.ID
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (c *Client) batchSize() int {
+	if c.BatchSize > 0 {
+		return c.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+func (c *Client) batchConcurrency() int {
+	if c.BatchConcurrency > 0 {
+		return c.BatchConcurrency
+	}
+	return DefaultBatchConcurrency
+}
@@ -0,0 +1,115 @@
+package sky
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that a batch is split into multiple requests and that rejected
+// requests report an error for only the rejected items.
+func TestTableInsertBatch(t *testing.T) {
+	var mutex sync.Mutex
+	var requests int
+	ids := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0"}`))
+			return
+		}
+		assert.Equal(t, r.Method, "PATCH")
+		assert.Equal(t, r.URL.Path, "/tables/t0/events")
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var m map[string]interface{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &m))
+			ids[m["id"].(string)] = true
+			if m["id"] == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), BatchSize: 100, BatchConcurrency: 2}
+	table := &Table{Client: c, Name: "t0"}
+	now := time.Now()
+	items := []ObjectEvent{}
+	for i := 0; i < 10; i++ {
+		items = append(items, ObjectEvent{ID: "o" + string(rune('0'+i)), Event: &Event{now, map[string]interface{}{}}})
+	}
+	items = append(items, ObjectEvent{ID: "", Event: &Event{now, nil}})
+	items = append(items, ObjectEvent{ID: "bad", Event: &Event{now, map[string]interface{}{}}})
+
+	err := table.InsertBatch(items)
+	errs, ok := err.(BatchErrors)
+	if assert.True(t, ok) && assert.Equal(t, len(errs), 2) {
+		assert.Equal(t, errs[0].Index, 10)
+		assert.Equal(t, errs[0].Err, ErrIDRequired)
+		assert.Equal(t, errs[1].ID, "bad")
+		assert.IsType(t, &APIError{}, errs[1].Err)
+	}
+	assert.True(t, requests > 1)
+	assert.Equal(t, len(ids), 11)
+}
+
+// Ensure that server errors are reported for every item in a request.
+func TestTableInsertBatchServerError(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0"}`))
+			return
+		}
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), BatchConcurrency: 1}
+	now := time.Now()
+	err := (&Table{Client: c, Name: "t0"}).InsertBatch([]ObjectEvent{
+		{ID: "o0", Event: &Event{now, map[string]interface{}{}}},
+		{ID: "o1", Event: &Event{now, map[string]interface{}{}}},
+	})
+	errs, ok := err.(BatchErrors)
+	if assert.True(t, ok) {
+		assert.Equal(t, len(errs), 2)
+	}
+	assert.Equal(t, requests, 1)
+}
+
+// Ensure that servers without bulk support are sent one request per event.
+func TestTableInsertBatchWithoutBulkEvents(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.5.0","capabilities":[]}`))
+			return
+		}
+		paths = append(paths, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	timestamp, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	err := (&Table{Client: c, Name: "t0"}).InsertEvents("o0", []*Event{
+		{timestamp, map[string]interface{}{}},
+		{timestamp.Add(time.Second), map[string]interface{}{}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []string{
+		"PATCH /tables/t0/objects/o0/events/1970-01-01T00:00:00Z",
+		"PATCH /tables/t0/objects/o0/events/1970-01-01T00:00:01Z",
+	})
+}
@@ -1,16 +1,18 @@
 package sky
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
-	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -25,6 +27,70 @@ const (
 type Client struct {
 	HTTPClient http.Client
 	Host       string
+
+	// Middleware wraps every request sent by the client. See Use.
+	Middleware []Middleware
+
+	// StreamListeners are notified of stream lifecycle events. See Listen.
+	StreamListeners []StreamListener
+
+	// Logger receives request summaries at debug level, successful stream
+	// reconnects at info level and failed reconnects and dropped events at
+	// warn level. Nothing is logged if it is nil.
+	Logger *slog.Logger
+
+	// LogBodySize is the number of bytes of request and response bodies that
+	// are logged. Bodies are not logged if it is zero.
+	LogBodySize int
+
+	// Tracer starts spans for requests, queries and stream flushes, if set.
+	Tracer Tracer
+
+	// DeadLetters receives events that are rejected by the server. Events
+	// sent through streams are not dead-lettered since the server does not
+	// report errors for individual stream events.
+	DeadLetters DeadLetterSink
+
+	// Deduper skips recently inserted duplicate events, if set.
+	Deduper *Deduper
+
+	// QueryValidator checks queries against the table's properties and the
+	// known values of its factors before they are sent to the server, if
+	// set. See skyql.Validate.
+	QueryValidator func(query string, properties []*Property, factorValues map[string][]string) error
+
+	// TypedEvents converts event data retrieved from a table to the Go types
+	// of the table's properties. Integer properties become int64 instead of
+	// float64. The table's properties are read from the schema cache.
+	TypedEvents bool
+
+	// BatchSize is the maximum number of bytes in a batch insert request.
+	BatchSize int
+
+	// BatchConcurrency is the number of batch insert requests sent in parallel.
+	BatchConcurrency int
+
+	// SchemaTTL is how long a table's properties are cached for validation
+	// and type conversion. Defaults to DefaultSchemaTTL. Properties are not
+	// cached if it is negative.
+	SchemaTTL time.Duration
+
+	// ListCacheTTL is how long pages read by table and property iterators
+	// are cached. Pages are not cached if it is zero.
+	ListCacheTTL time.Duration
+
+	// PageSize is the number of tables or properties requested per page on
+	// servers that support paging. Defaults to DefaultPageSize.
+	PageSize int
+
+	mutex      sync.Mutex
+	serverInfo *ServerInfo
+
+	cacheMutex sync.Mutex
+	listCache  map[string]*cachedList
+
+	schemaMutex sync.Mutex
+	schemas     map[string]*schema
 }
 
 // Constructs a URL based on the client's host, port and a given path.
@@ -32,49 +98,78 @@ func (c *Client) URL(path string) *url.URL {
 	return &url.URL{Scheme: "http", Host: c.Host, Path: path}
 }
 
+// queryURL constructs a URL with query parameters. The path is escaped as a
+// whole so a "?" in a table name or object id stays part of the path.
+func (c *Client) queryURL(path string, query url.Values) *url.URL {
+	u := c.URL(path)
+	u.RawQuery = query.Encode()
+	return u
+}
+
 // Send sends low-level data to and from the server.
 func (c *Client) Send(method string, path string, data interface{}, ret interface{}) error {
-	url := c.URL(path)
+	return c.SendContext(context.Background(), method, path, data, ret)
+}
+
+// SendContext sends low-level data to and from the server. If the client has
+// a tracer then the request's span is started as a child of the context.
+func (c *Client) SendContext(ctx context.Context, method string, path string, data interface{}, ret interface{}) error {
+	return c.sendQuery(ctx, method, path, nil, data, ret)
+}
 
+// sendQuery sends low-level data to and from the server with query
+// parameters.
+func (c *Client) sendQuery(ctx context.Context, method string, path string, query url.Values, data interface{}, ret interface{}) error {
 	// Convert the data to JSON.
 	var err error
 	var body []byte
+	var contentType = "application/json"
 	if str, ok := data.(string); ok {
 		body = []byte(str)
+		contentType = "text/plain"
 	} else if data != nil {
 		body, err = json.Marshal(data)
 		if err != nil {
 			return err
 		}
 	}
+	return c.send(ctx, method, path, query, contentType, body, ret)
+}
+
+// send sends an encoded request body to the server and decodes the response
+// into ret, if it is not nil.
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, contentType string, body []byte, ret interface{}) (err error) {
+	url := c.queryURL(path, query)
+	ctx, span := c.startSpan(ctx, "sky.send", Attribute{"http.method", method}, Attribute{"http.path", path})
+	defer func() { span.End(err) }()
 
 	// Create the request object.
-	req, err := http.NewRequest(method, url.String(), strings.NewReader(string(body)))
+	req, err := http.NewRequest(method, url.String(), bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
-	if _, ok := data.(string); ok {
-		req.Header.Add("Content-Type", "text/plain")
-	} else {
-		req.Header.Add("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+	req.Header.Add("Content-Type", contentType)
+	if traceParent := span.TraceParent(); traceParent != "" {
+		req.Header.Set(TraceParentHeader, traceParent)
 	}
 
 	// Send the request to the server.
-	resp, err := c.HTTPClient.Do(req)
+	t := time.Now()
+	resp, err := c.doer().Do(req)
+	c.logResponse(req, body, resp, err, time.Since(t))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(Attribute{"http.status_code", resp.StatusCode})
 
 	// If we have a return object then deserialize to it.
 	if resp.StatusCode != http.StatusOK {
 		var m message
 		b, _ := ioutil.ReadAll(resp.Body)
-		if json.Unmarshal(b, &m); m.Message != "" {
-			return errors.New(m.Message)
-		} else {
-			return fmt.Errorf("%d error: %s %s", resp.StatusCode, method, url.String())
-		}
+		json.Unmarshal(b, &m)
+		return &APIError{StatusCode: resp.StatusCode, Message: m.Message, Method: method, URL: url.String()}
 	}
 
 	// Deserialize data into return object if we have one.
@@ -100,8 +195,19 @@ func (c *Client) Table(name string) (*Table, error) {
 	return table, nil
 }
 
-// Tables retrieves a list of all table on the server.
+// Tables retrieves a list of all table on the server. Servers that support
+// paging are read one page at a time and the tables are returned in name
+// order. Otherwise they are returned in the server's order.
 func (c *Client) Tables() ([]*Table, error) {
+	if c.supports(CapabilityPaging) {
+		tables := make([]*Table, 0)
+		i := c.TableIterator("")
+		for i.Next() {
+			tables = append(tables, i.Table())
+		}
+		return tables, i.Err()
+	}
+
 	tables := make([]*Table, 0)
 	if err := c.Send("GET", "/tables", nil, &tables); err != nil {
 		return nil, err
@@ -117,6 +223,7 @@ func (c *Client) CreateTable(t *Table) error {
 		return ErrTableRequired
 	}
 	t.Client = c
+	defer c.clearListCache()
 	return c.Send("POST", "/tables", t, t)
 }
 
@@ -124,11 +231,19 @@ func (c *Client) DeleteTable(name string) error {
 	if name == "" {
 		return ErrTableNameRequired
 	}
+	defer c.clearListCache()
+	defer c.invalidateSchema(name)
 	return c.Send("DELETE", path.Join("/tables", name), nil, nil)
 }
 
 func (c *Client) Ping() bool {
-	err := c.Send("GET", "/ping", nil, nil)
+	return c.PingContext(context.Background())
+}
+
+// PingContext checks that the server is reachable. The request is canceled
+// when the context is done.
+func (c *Client) PingContext(ctx context.Context) bool {
+	err := c.SendContext(ctx, "GET", "/ping", nil, nil)
 	return (err == nil)
 }
 
@@ -136,14 +251,6 @@ func (c *Client) Stream() (*EventStream, error) {
 	return NewEventStream(c)
 }
 
-func warn(v ...interface{}) {
-	fmt.Fprintln(os.Stderr, v...)
-}
-
-func warnf(msg string, v ...interface{}) {
-	fmt.Fprintf(os.Stderr, msg+"\n", v...)
-}
-
 // message is a generic return message from Sky that can occur on error.
 type message struct {
 	Message string `json:"message"`
@@ -1,6 +1,12 @@
 package sky
 
 import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -78,3 +84,55 @@ func TestClientTableEventStream(t *testing.T) {
 		}
 	})
 }
+
+// Ensure that stream inserts are only sent through the stream.
+func TestStreamInsertEvent(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	lines := make(chan int, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mutex.Unlock()
+		if r.Proto != "HTTP/1.0" {
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var n int
+		scanner := bufio.NewScanner(httputil.NewChunkedReader(rw))
+		for scanner.Scan() {
+			n++
+		}
+		lines <- n
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	table := &Table{Client: c, Name: "t0"}
+	event := &Event{Timestamp: time.Unix(0, 0), Data: map[string]interface{}{"action": "home"}}
+
+	tableStream, err := table.Stream()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, tableStream.InsertEvent("o0", event))
+	assert.NoError(t, tableStream.InsertEvent("o1", event))
+	assert.NoError(t, tableStream.Close())
+	assert.Equal(t, <-lines, 2)
+
+	stream, err := c.Stream()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, stream.InsertEvent(table, "o0", event))
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, <-lines, 1)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, requests, []string{"PATCH /tables/t0/events", "PATCH /events"})
+}
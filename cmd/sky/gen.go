@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"sort"
+	"text/template"
+	"unicode"
+
+	"github.com/daemonchen/gosky"
+)
+
+// gen generates Go types and helpers for the events of one or more tables.
+func gen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	host := fs.String("host", sky.DefaultHost, "Sky server host")
+	pkg := fs.String("package", "models", "package name of the generated file")
+	output := fs.String("o", "", "output file (default stdout)")
+	maxValues := fs.Int("max-values", 100, "maximum factor values to generate constants for")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sky gen [-host HOST] [-package NAME] [-o FILE] [-max-values N] TABLE...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("table name required")
+	}
+
+	c := &sky.Client{Host: *host}
+	var tables []*genTable
+	used := map[string]bool{}
+	for _, name := range fs.Args() {
+		t, err := c.Table(name)
+		if err != nil {
+			return err
+		}
+		t.Name = name
+		properties, err := t.Properties()
+		if err != nil {
+			return err
+		}
+		values := map[string][]string{}
+		for _, p := range properties {
+			if p.DataType != sky.Factor || !queryable(p.Name) {
+				continue
+			}
+			if values[p.Name], err = factorValues(t, p.Name); err != nil {
+				return err
+			}
+			if len(values[p.Name]) > *maxValues {
+				values[p.Name] = nil
+			}
+		}
+		tables = append(tables, newGenTable(name, properties, values, used))
+	}
+
+	// Generate into memory first so that a failure does not truncate an
+	// existing output file.
+	var buf bytes.Buffer
+	if err := generate(&buf, *pkg, tables); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// factorValues returns the values of a factor property that occur in the
+// table's events.
+func factorValues(t *sky.Table, name string) ([]string, error) {
+	if !queryable(name) {
+		return nil, fmt.Errorf("invalid property name: %q", name)
+	}
+	output, err := t.Query(fmt.Sprintf("SELECT count() GROUP BY %s", name))
+	if err != nil {
+		return nil, err
+	}
+	m, _ := output[name].(map[string]interface{})
+	values := []string{}
+	for value := range m {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// queryable returns true if a property name can be used in a query. Factors
+// with other names are generated without constants.
+func queryable(name string) bool {
+	for i, ch := range name {
+		if ch != '_' && !unicode.IsLetter(ch) && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// genTable is the template data for a table.
+type genTable struct {
+	Name       string
+	Type       string
+	Properties []*genProperty
+}
+
+// genProperty is the template data for a property.
+type genProperty struct {
+	Name      string
+	Field     string
+	Type      string
+	Accessor  string
+	Factor    bool
+	Constants []*genConstant
+}
+
+// genConstant is a known value of a factor property.
+type genConstant struct {
+	Name  string
+	Value string
+}
+
+// newGenTable converts a table's properties to template data. Properties are
+// sorted by name so the output is stable. Top-level names already in used are
+// given a numeric suffix and the table's names are added to used.
+func newGenTable(name string, properties []*sky.Property, values map[string][]string, used map[string]bool) *genTable {
+	t := &genTable{Name: name, Type: tableType(identifier(name), used)}
+	sorted := append([]*sky.Property{}, properties...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	fields := map[string]bool{"Timestamp": true, "Event": true}
+	for _, p := range sorted {
+		gp := &genProperty{Name: p.Name, Field: unique(identifier(p.Name), fields)}
+		switch p.DataType {
+		case sky.Integer:
+			gp.Type, gp.Accessor = "int64", "Int"
+		case sky.Float:
+			gp.Type, gp.Accessor = "float64", "Float"
+		case sky.Boolean:
+			gp.Type, gp.Accessor = "bool", "Bool"
+		case sky.Factor:
+			gp.Type, gp.Accessor, gp.Factor = unique(t.Type+gp.Field, used), "String", true
+			for _, v := range values[p.Name] {
+				gp.Constants = append(gp.Constants, &genConstant{Name: unique(gp.Type+identifier(v), used), Value: v})
+			}
+		default:
+			gp.Type, gp.Accessor = "string", "String"
+		}
+		t.Properties = append(t.Properties, gp)
+	}
+	return t
+}
+
+// tableType returns the type name for a table such that none of the names
+// generated from it are in used, and adds those names to used.
+func tableType(name string, used map[string]bool) string {
+	typ := name
+	for i := 2; ; i++ {
+		names := tableNames(typ)
+		free := true
+		for _, s := range names {
+			free = free && !used[s]
+		}
+		if free {
+			for _, s := range names {
+				used[s] = true
+			}
+			return typ
+		}
+		typ = fmt.Sprintf("%s%d", name, i)
+	}
+}
+
+// tableNames returns the top-level names generated for a table type.
+func tableNames(typ string) []string {
+	return []string{typ + "Event", "New" + typ + "Event", "Insert" + typ + "Event", typ + "Events"}
+}
+
+// identifier converts a name to an exported Go identifier. Characters that
+// are not letters or digits separate words.
+func identifier(name string) string {
+	var buf bytes.Buffer
+	upper := true
+	for _, ch := range name {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) {
+			upper = true
+			continue
+		}
+		if upper {
+			ch = unicode.ToUpper(ch)
+			upper = false
+		}
+		buf.WriteRune(ch)
+	}
+	s := buf.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// unique appends a number to name if it has already been used.
+func unique(name string, used map[string]bool) string {
+	s := name
+	for i := 2; used[s]; i++ {
+		s = fmt.Sprintf("%s%d", name, i)
+	}
+	used[s] = true
+	return s
+}
+
+// generate writes the formatted Go source for a set of tables.
+func generate(w io.Writer, pkg string, tables []*genTable) error {
+	var buf bytes.Buffer
+	if err := genTemplate.Execute(&buf, map[string]interface{}{"Package": pkg, "Tables": tables}); err != nil {
+		return err
+	}
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+var genTemplate = template.Must(template.New("gen").Parse(`// Code generated by "sky gen"; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+{{range $t := .Tables}}
+{{range $p := .Properties}}{{if .Factor}}
+// {{.Type}} is a value of the "{{.Name}}" factor on the "{{$t.Name}}" table.
+type {{.Type}} string
+{{if .Constants}}
+// Known values of {{.Type}}.
+const (
+{{range .Constants}}	{{.Name}} {{$p.Type}} = {{printf "%q" .Value}}
+{{end}})
+{{end}}{{end}}{{end}}
+// {{.Type}}Event is an event on the "{{.Name}}" table. Nil fields are not set
+// on the event.
+type {{.Type}}Event struct {
+	Timestamp time.Time
+{{range .Properties}}	{{.Field}} *{{.Type}}
+{{end}}}
+
+// Event converts e to a sky.Event.
+func (e *{{.Type}}Event) Event() *sky.Event {
+	data := map[string]interface{}{}
+{{range .Properties}}	if e.{{.Field}} != nil {
+		data["{{.Name}}"] = {{if .Factor}}string(*e.{{.Field}}){{else}}*e.{{.Field}}{{end}}
+	}
+{{end}}	return &sky.Event{Timestamp: e.Timestamp, Data: data}
+}
+
+// New{{.Type}}Event converts a sky.Event to a {{.Type}}Event. Values with the
+// wrong type are ignored.
+func New{{.Type}}Event(e *sky.Event) *{{.Type}}Event {
+	out := &{{.Type}}Event{Timestamp: e.Timestamp}
+{{range .Properties}}	if v, ok := e.{{.Accessor}}("{{.Name}}"); ok {
+		{{if .Factor}}value := {{.Type}}(v)
+		out.{{.Field}} = &value{{else}}out.{{.Field}} = &v{{end}}
+	}
+{{end}}	return out
+}
+
+// Insert{{.Type}}Event adds an event to an object on the "{{.Name}}" table.
+func Insert{{.Type}}Event(t *sky.Table, id string, e *{{.Type}}Event) error {
+	return t.InsertEvent(id, e.Event())
+}
+
+// {{.Type}}Events retrieves all events for an object on the "{{.Name}}" table.
+func {{.Type}}Events(t *sky.Table, id string) ([]*{{.Type}}Event, error) {
+	events, err := t.Events(id)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]*{{.Type}}Event, len(events))
+	for i, e := range events {
+		output[i] = New{{.Type}}Event(e)
+	}
+	return output, nil
+}
+{{end}}`))
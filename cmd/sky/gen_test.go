@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that names are converted to exported identifiers.
+func TestIdentifier(t *testing.T) {
+	assert.Equal(t, identifier("page_views"), "PageViews")
+	assert.Equal(t, identifier("sign up!"), "SignUp")
+	assert.Equal(t, identifier("404"), "X404")
+	assert.Equal(t, identifier(""), "X")
+}
+
+// Ensure that types, constants and helpers are generated for a table.
+func TestGenerate(t *testing.T) {
+	table := newGenTable("page_views", []*sky.Property{
+		{Name: "path", Transient: true, DataType: sky.String},
+		{Name: "action", Transient: true, DataType: sky.Factor},
+		{Name: "count", DataType: sky.Integer},
+		{Name: "timestamp", DataType: sky.Boolean},
+	}, map[string][]string{"action": {"sign-up", "sign_up", "view"}}, map[string]bool{})
+
+	var buf bytes.Buffer
+	assert.NoError(t, generate(&buf, "models", []*genTable{table}))
+	typeCheck(t, buf.Bytes())
+
+	src := buf.String()
+	assert.Contains(t, src, "type PageViewsAction string")
+	assert.Contains(t, src, `PageViewsActionSignUp  PageViewsAction = "sign-up"`)
+	assert.Contains(t, src, `PageViewsActionSignUp2 PageViewsAction = "sign_up"`)
+	assert.Contains(t, src, "Action     *PageViewsAction\n")
+	assert.Contains(t, src, "Count      *int64\n")
+	assert.Contains(t, src, "Timestamp2 *bool\n")
+	assert.Contains(t, src, "func NewPageViewsEvent(e *sky.Event) *PageViewsEvent {")
+	assert.Contains(t, src, "func InsertPageViewsEvent(t *sky.Table, id string, e *PageViewsEvent) error {")
+	assert.Contains(t, src, "func PageViewsEvents(t *sky.Table, id string) ([]*PageViewsEvent, error) {")
+}
+
+// Ensure that factor types and constants do not collide with other generated
+// names.
+func TestGenerateCollisions(t *testing.T) {
+	used := map[string]bool{}
+	users := newGenTable("users", []*sky.Property{
+		{Name: "event", DataType: sky.Factor},
+		{Name: "events", DataType: sky.Factor},
+		{Name: "events_event", DataType: sky.Factor},
+	}, map[string][]string{"events": {"x"}}, used)
+	other := newGenTable("users_events", []*sky.Property{}, nil, used)
+
+	var buf bytes.Buffer
+	assert.NoError(t, generate(&buf, "models", []*genTable{users, other}))
+	typeCheck(t, buf.Bytes())
+
+	src := buf.String()
+	assert.Contains(t, src, "type UsersEvent struct {")
+	assert.Contains(t, src, "type UsersEvent2 string")
+	assert.Contains(t, src, "type UsersEvents2 string")
+	assert.Contains(t, src, `UsersEvents2X UsersEvents2 = "x"`)
+	assert.Contains(t, src, "Event2      *UsersEvent2\n")
+	assert.Contains(t, src, "type UsersEventsEvent string")
+	assert.Contains(t, src, "type UsersEvents2Event struct {")
+}
+
+// Ensure that only names usable in a query are queried for factor values.
+func TestQueryable(t *testing.T) {
+	assert.True(t, queryable("action_2"))
+	assert.False(t, queryable("req.latency"))
+	assert.False(t, queryable("2x"))
+	assert.False(t, queryable("x GROUP BY y"))
+	assert.False(t, queryable(""))
+}
+
+// typeCheck parses and type-checks generated source.
+func typeCheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "models.go", src, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("models", fset, []*ast.File{f}, nil)
+	assert.NoError(t, err, string(src))
+}
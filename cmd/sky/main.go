@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: sky <command> [arguments]
+
+The commands are:
+
+    gen        generate Go types from table schemas
+    redrive    re-send events from a dead letter file
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "gen":
+		err = gen(args)
+	case "redrive":
+		err = redrive(args)
+	default:
+		fmt.Fprintf(os.Stderr, "sky: unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sky:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/daemonchen/gosky"
+)
+
+// redrive re-sends the events in a dead letter file. Events that are rejected
+// again are written back to the file so the command can be re-run after the
+// schema is fixed.
+func redrive(args []string) error {
+	fs := flag.NewFlagSet("redrive", flag.ExitOnError)
+	host := fs.String("host", sky.DefaultHost, "Sky server host")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sky redrive [-host HOST] FILE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("dead letter file required")
+	}
+	path := fs.Arg(0)
+
+	// Read all dead letters into memory before rewriting the file. The file
+	// stays open so it can be rewritten in place.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	letters, err := sky.ReadDeadLetters(f)
+	if err != nil {
+		return err
+	}
+	n, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	// Re-send the events. Remaining letters are saved even on error.
+	c := &sky.Client{Host: *host}
+	failed, err := c.Redrive(letters)
+	if werr := rewriteDeadLetters(f, n, failed); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("redrove %d of %d events\n", len(letters)-len(failed), len(letters))
+	for _, d := range failed {
+		fmt.Printf("rejected: table=%s id=%s: %v\n", d.Table, d.ID, d.Error)
+	}
+	return nil
+}
+
+// rewriteDeadLetters replaces the first n bytes of the file with the given
+// letters. Letters appended after the first n bytes, such as by a running
+// FileDeadLetterSink, are kept. The file is rewritten in place instead of
+// renamed over so that its permissions are kept and an open sink continues to
+// append to it. Letters appended during the rewrite itself can be lost.
+func rewriteDeadLetters(f *os.File, n int64, letters []*sky.DeadLetter) error {
+	tail, err := ioutil.ReadAll(io.NewSectionReader(f, n, math.MaxInt64-n))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := sky.WriteDeadLetters(&buf, letters); err != nil {
+		return err
+	}
+	buf.Write(tail)
+	if _, err := f.WriteAt(buf.Bytes(), 0); err != nil {
+		return err
+	}
+	if err := f.Truncate(int64(buf.Len())); err != nil {
+		return err
+	}
+	return f.Sync()
+}
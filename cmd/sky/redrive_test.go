@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that dead letter files are rewritten in place and keep letters
+// appended by an open sink.
+func TestRewriteDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.json")
+	sink, err := sky.OpenFileDeadLetterSink(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sink.Close()
+	assert.NoError(t, sink.WriteDeadLetter(&sky.DeadLetter{Table: "t0", ID: "o0"}))
+	assert.NoError(t, sink.WriteDeadLetter(&sky.DeadLetter{Table: "t0", ID: "o1"}))
+	before, _ := os.Stat(path)
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	letters, err := sky.ReadDeadLetters(f)
+	assert.NoError(t, err)
+	n, _ := f.Seek(0, io.SeekCurrent)
+
+	// Letters written during the redrive are kept, as are later ones.
+	assert.NoError(t, sink.WriteDeadLetter(&sky.DeadLetter{Table: "t0", ID: "o2"}))
+	assert.NoError(t, rewriteDeadLetters(f, n, letters[1:]))
+	assert.NoError(t, sink.WriteDeadLetter(&sky.DeadLetter{Table: "t0", ID: "o3"}))
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, string(b), ""+
+		`{"error":null,"event":null,"id":"o1","table":"t0"}`+"\n"+
+		`{"error":null,"event":null,"id":"o2","table":"t0"}`+"\n"+
+		`{"error":null,"event":null,"id":"o3","table":"t0"}`+"\n")
+
+	after, _ := os.Stat(path)
+	assert.True(t, os.SameFile(before, after))
+	assert.Equal(t, after.Mode(), before.Mode())
+}
@@ -0,0 +1,240 @@
+package sky
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Copier copies the properties and events of a table to another table. The
+// tables may be on different servers.
+type Copier struct {
+	Source *Table
+	Dest   *Table
+
+	// Rename maps source property names to destination property names.
+	Rename map[string]string
+
+	// Drop lists source properties that are not copied.
+	Drop []string
+
+	// Start and End limit the copy to events at or after Start and before
+	// End. Zero times are unbounded.
+	Start time.Time
+	End   time.Time
+
+	// SampleRate is the fraction of objects to copy. Objects are chosen by a
+	// hash of their identifier so the same objects are sampled by each copy.
+	// If zero then all objects are copied.
+	SampleRate float64
+
+	// Concurrency is the number of objects copied at once. Defaults to
+	// DefaultBatchConcurrency.
+	Concurrency int
+
+	// Progress is called after each object is copied. Calls are made one at
+	// a time in the order the objects finish so it does not need to be safe
+	// for concurrent use, but a slow callback slows down the copy.
+	Progress func(CopyProgress)
+}
+
+// CopyProgress is the state of a running copy.
+type CopyProgress struct {
+	Objects int // objects copied or skipped
+	Total   int // objects in the source table
+	Skipped int // objects skipped by sampling
+	Events  int // events copied
+}
+
+// Copy creates the destination table and properties if they do not exist and
+// copies the events of each object.
+func (c *Copier) Copy() (*CopyProgress, error) {
+	if c.Source == nil || c.Dest == nil {
+		return nil, ErrTableRequired
+	} else if c.Source.Client == nil || c.Dest.Client == nil {
+		return nil, ErrClientRequired
+	}
+	if err := c.createTable(); err != nil {
+		return nil, err
+	}
+	if err := c.createProperties(); err != nil {
+		return nil, err
+	}
+	keys, err := c.Source.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	var mutex sync.Mutex
+	var once sync.Once
+	var copyErr error
+	progress := &CopyProgress{Total: len(keys)}
+	ids := make(chan string)
+	done := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			copyErr = err
+			close(done)
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var stream *TableEventStream
+			defer func() {
+				if stream != nil {
+					if err := stream.Close(); err != nil {
+						fail(err)
+					}
+				}
+			}()
+			for id := range ids {
+				n, sampled := 0, c.sampled(id)
+				if sampled {
+					events, err := c.events(id)
+					if err != nil {
+						fail(err)
+						return
+					}
+					if stream == nil && len(events) > 0 {
+						if stream, err = c.Dest.Stream(); err != nil {
+							fail(err)
+							return
+						}
+					}
+					for _, e := range events {
+						if err := stream.InsertEvent(id, e); err != nil {
+							fail(err)
+							return
+						}
+					}
+					n = len(events)
+				}
+
+				mutex.Lock()
+				progress.Objects++
+				progress.Events += n
+				if !sampled {
+					progress.Skipped++
+				}
+				if c.Progress != nil {
+					c.Progress(*progress)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+loop:
+	for _, id := range keys {
+		select {
+		case ids <- id:
+		case <-done:
+			break loop
+		}
+	}
+	close(ids)
+	wg.Wait()
+	return progress, copyErr
+}
+
+// createTable creates the destination table if it does not exist.
+func (c *Copier) createTable() error {
+	_, err := c.Dest.Client.Table(c.Dest.Name)
+	if err, ok := err.(*APIError); ok && err.StatusCode == http.StatusNotFound {
+		return c.Dest.Client.CreateTable(c.Dest)
+	}
+	return err
+}
+
+// createProperties creates the mapped source properties that do not exist on
+// the destination table.
+func (c *Copier) createProperties() error {
+	source, err := c.Source.Properties()
+	if err != nil {
+		return err
+	}
+	dest, err := c.Dest.Properties()
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, p := range dest {
+		exists[p.Name] = true
+	}
+	for _, p := range source {
+		name, ok := c.propertyName(p.Name)
+		if !ok || exists[name] {
+			continue
+		}
+		if err := c.Dest.CreateProperty(&Property{Name: name, Transient: p.Transient, DataType: p.DataType}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propertyName returns the destination name of a source property. Returns
+// false if the property is dropped.
+func (c *Copier) propertyName(name string) (string, bool) {
+	for _, drop := range c.Drop {
+		if drop == name {
+			return "", false
+		}
+	}
+	if newName, ok := c.Rename[name]; ok {
+		return newName, true
+	}
+	return name, true
+}
+
+// events retrieves an object's events in the copy's time range with their
+// properties mapped to the destination. Events whose properties are all
+// dropped are skipped.
+func (c *Copier) events(id string) ([]*Event, error) {
+	var events []*Event
+	var err error
+	if c.Start.IsZero() && c.End.IsZero() {
+		events, err = c.Source.Events(id)
+	} else {
+		events, err = c.Source.EventsBetween(id, c.Start, c.End)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := events[:0]
+	for _, e := range events {
+		data := make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			if name, ok := c.propertyName(k); ok {
+				data[name] = v
+			}
+		}
+		if len(data) == 0 && len(e.Data) > 0 {
+			continue
+		}
+		e.Data = data
+		mapped = append(mapped, e)
+	}
+	return mapped, nil
+}
+
+// sampled returns true if an object is included in the copy's sample.
+func (c *Copier) sampled(id string) bool {
+	if c.SampleRate <= 0 || c.SampleRate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) < c.SampleRate*10000
+}
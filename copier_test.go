@@ -0,0 +1,101 @@
+package sky
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that a table can be copied to another server with properties
+// renamed, dropped and filtered by time. Events left without properties are
+// skipped.
+func TestCopierCopy(t *testing.T) {
+	src, srcClient := newBackupServer(t)
+	defer src.Close()
+	dest, destClient := newFakeServer(t)
+	defer dest.Close()
+
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	var progress []CopyProgress
+	copier := &Copier{
+		Source:      &Table{Client: srcClient, Name: "t0"},
+		Dest:        &Table{Client: destClient, Name: "staging"},
+		Rename:      map[string]string{"gender": "sex"},
+		Drop:        []string{"price"},
+		Start:       t0,
+		Concurrency: 1,
+		Progress:    func(p CopyProgress) { progress = append(progress, p) },
+	}
+	p, err := copier.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, *p, CopyProgress{Objects: 2, Total: 2, Events: 2})
+	assert.Equal(t, len(progress), 2)
+	assert.Equal(t, progress[1], *p)
+	dest.waitStreams(1)
+
+	table := dest.tables["staging"]
+	if assert.NotNil(t, table) {
+		assert.Equal(t, table.properties, []*Property{{Name: "sex", DataType: Factor}})
+		assert.Equal(t, table.events("o0"), []map[string]interface{}{
+			{"timestamp": "1970-01-01T00:00:00Z", "data": map[string]interface{}{"sex": "m"}},
+		})
+		assert.Equal(t, len(table.objects), 2)
+	}
+}
+
+// Ensure that objects are sampled consistently by identifier.
+func TestCopierSample(t *testing.T) {
+	src, c := newFakeServer(t)
+	defer src.Close()
+	table := src.table("t0")
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	for i := 0; i < 100; i++ {
+		table.insert(string(rune('a'+i%26))+string(rune('a'+i/26)), &Event{Timestamp: t0, Data: map[string]interface{}{}})
+	}
+
+	copyTo := func(name string) *CopyProgress {
+		p, err := (&Copier{Source: &Table{Client: c, Name: "t0"}, Dest: &Table{Client: c, Name: name}, SampleRate: 0.5, Concurrency: 1}).Copy()
+		assert.NoError(t, err)
+		return p
+	}
+	p := copyTo("t1")
+	src.waitStreams(1)
+	assert.Equal(t, p.Objects, 100)
+	assert.True(t, p.Skipped > 25 && p.Skipped < 75)
+	assert.Equal(t, p.Events, 100-p.Skipped)
+	assert.Equal(t, len(src.tables["t1"].objects), p.Events)
+
+	copyTo("t2")
+	src.waitStreams(2)
+	assert.Equal(t, src.tables["t2"].objects, src.tables["t1"].objects)
+}
+
+// Ensure that progress is reported one object at a time with concurrent
+// workers.
+func TestCopierProgress(t *testing.T) {
+	src, c := newFakeServer(t)
+	defer src.Close()
+	table := src.table("t0")
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	for i := 0; i < 100; i++ {
+		table.insert(string(rune('a'+i%26))+string(rune('a'+i/26)), &Event{Timestamp: t0, Data: map[string]interface{}{}})
+	}
+
+	// Objects are all skipped by sampling so that no streams are opened.
+	var objects []int
+	copier := &Copier{
+		Source:      &Table{Client: c, Name: "t0"},
+		Dest:        &Table{Client: c, Name: "t1"},
+		SampleRate:  1e-9,
+		Concurrency: 8,
+		Progress:    func(p CopyProgress) { objects = append(objects, p.Objects) },
+	}
+	p, err := copier.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, p.Skipped, 100)
+	if assert.Equal(t, len(objects), 100) {
+		for i, n := range objects {
+			assert.Equal(t, n, i+1)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package sky
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// DeadLetter represents an event that was rejected by the server.
+type DeadLetter struct {
+	Table string
+	ID    string
+	Event *Event
+	Error *APIError
+}
+
+// MarshalJSON encodes the dead letter with the event in its wire format.
+func (d *DeadLetter) MarshalJSON() ([]byte, error) {
+	var event map[string]interface{}
+	if d.Event != nil {
+		event = d.Event.Serialize()
+	}
+	return json.Marshal(map[string]interface{}{
+		"table": d.Table,
+		"id":    d.ID,
+		"event": event,
+		"error": d.Error,
+	})
+}
+
+// UnmarshalJSON decodes a dead letter previously encoded with MarshalJSON.
+func (d *DeadLetter) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		Table string                 `json:"table"`
+		ID    string                 `json:"id"`
+		Event map[string]interface{} `json:"event"`
+		Error *APIError              `json:"error"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	d.Table, d.ID, d.Error = tmp.Table, tmp.ID, tmp.Error
+	d.Event = nil
+	if tmp.Event != nil {
+		d.Event = &Event{}
+		if err := d.Event.Deserialize(tmp.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadLetterSink receives events that were rejected by the server.
+type DeadLetterSink interface {
+	WriteDeadLetter(*DeadLetter) error
+}
+
+// FileDeadLetterSink appends dead letters to a file as newline-delimited JSON.
+type FileDeadLetterSink struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// OpenFileDeadLetterSink opens a file for appending dead letters. The file is
+// created if it does not exist.
+func OpenFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{file: f, encoder: json.NewEncoder(f)}, nil
+}
+
+// WriteDeadLetter appends a single dead letter to the file.
+func (s *FileDeadLetterSink) WriteDeadLetter(d *DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encoder.Encode(d)
+}
+
+// Close closes the underlying file.
+func (s *FileDeadLetterSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
+
+// ReadDeadLetters decodes a stream of newline-delimited dead letters.
+func ReadDeadLetters(r io.Reader) ([]*DeadLetter, error) {
+	letters := []*DeadLetter{}
+	decoder := json.NewDecoder(r)
+	for {
+		d := &DeadLetter{}
+		if err := decoder.Decode(d); err == io.EOF {
+			return letters, nil
+		} else if err != nil {
+			return nil, err
+		}
+		letters = append(letters, d)
+	}
+}
+
+// WriteDeadLetters encodes a list of dead letters as newline-delimited JSON.
+func WriteDeadLetters(w io.Writer, letters []*DeadLetter) error {
+	encoder := json.NewEncoder(w)
+	for _, d := range letters {
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redrive attempts to insert dead letters again. Letters that are rejected
+// again are returned with their updated error and are not written back to the
+// client's dead letter sink. Letters without an event are skipped and returned
+// unchanged. If any other error occurs, such as a server error or a missing
+// table, then the remaining letters are returned along with the error.
+func (c *Client) Redrive(letters []*DeadLetter) ([]*DeadLetter, error) {
+	return c.RedriveContext(context.Background(), letters)
+}
+
+// RedriveContext attempts to insert dead letters again. If the client has a
+// tracer then each insert's span is started as a child of the context.
+func (c *Client) RedriveContext(ctx context.Context, letters []*DeadLetter) ([]*DeadLetter, error) {
+	failed := []*DeadLetter{}
+	for i, d := range letters {
+		if d.Event == nil {
+			failed = append(failed, d)
+			continue
+		}
+		t := &Table{Client: c, Name: d.Table}
+		if err := t.insertEvent(ctx, d.ID, d.Event, false); err != nil {
+			if err, ok := rejection(err); ok {
+				failed = append(failed, &DeadLetter{Table: d.Table, ID: d.ID, Event: d.Event, Error: err})
+				continue
+			}
+			return append(failed, letters[i:]...), err
+		}
+	}
+	return failed, nil
+}
+
+// rejection returns err as an APIError if the server rejected an event as
+// invalid. Server errors and missing tables are not rejections since the
+// event may be accepted once they are resolved.
+func rejection(err error) (*APIError, bool) {
+	e, ok := err.(*APIError)
+	if !ok || e.StatusCode < 400 || e.StatusCode >= 500 || e.StatusCode == http.StatusNotFound {
+		return nil, false
+	}
+	return e, true
+}
+
+// deadLetter sends a rejected event to the client's dead letter sink, if one
+// is configured.
+func (c *Client) deadLetter(table string, id string, e *Event, err *APIError) {
+	if c.DeadLetters == nil {
+		return
+	}
+	if err := c.DeadLetters.WriteDeadLetter(&DeadLetter{Table: table, ID: id, Event: e, Error: err}); err != nil {
+		c.logger().Warn("sky: dropped event: unable to write dead letter", "table", table, "id", id, "error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package sky
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that rejected events are written to the dead letter sink and can be read back.
+func TestDeadLetterFileSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"property not found: foo"}`))
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "sky-")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dead.ndjson")
+	sink, err := OpenFileDeadLetterSink(path)
+	assert.NoError(t, err)
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), DeadLetters: sink}
+	table := &Table{Client: c, Name: "t0"}
+	timestamp, _ := ParseTimestamp("1970-01-01T00:00:01.5Z")
+	err = table.InsertEvent("o0", &Event{timestamp, map[string]interface{}{"foo": "bar"}})
+	assert.Equal(t, err.Error(), "property not found: foo")
+	assert.NoError(t, sink.Close())
+
+	// Read the dead letters back.
+	f, _ := os.Open(path)
+	defer f.Close()
+	letters, err := ReadDeadLetters(f)
+	assert.NoError(t, err)
+	if assert.Equal(t, len(letters), 1) {
+		assert.Equal(t, letters[0].Table, "t0")
+		assert.Equal(t, letters[0].ID, "o0")
+		assert.Equal(t, letters[0].Event.Timestamp, timestamp)
+		assert.Equal(t, letters[0].Event.Data["foo"], "bar")
+		assert.Equal(t, letters[0].Error.StatusCode, http.StatusBadRequest)
+		assert.Equal(t, letters[0].Error.Message, "property not found: foo")
+	}
+}
+
+// Ensure that redriving returns only the letters that were rejected again.
+func TestClientRedrive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/objects/bad/") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	timestamp, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	letters := []*DeadLetter{
+		{Table: "t0", ID: "good", Event: &Event{timestamp, map[string]interface{}{}}},
+		{Table: "t0", ID: "bad", Event: &Event{timestamp, map[string]interface{}{}}},
+		{Table: "t0", ID: "empty"},
+	}
+	failed, err := c.Redrive(letters)
+	assert.NoError(t, err)
+	if assert.Equal(t, len(failed), 2) {
+		assert.Equal(t, failed[0].ID, "bad")
+		assert.Equal(t, failed[0].Error.StatusCode, http.StatusBadRequest)
+		assert.Equal(t, failed[1], letters[2])
+	}
+}
+
+// Ensure that server errors and missing tables are returned rather than
+// dead lettered.
+func TestDeadLetterRetryableErrors(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &memoryDeadLetterSink{}
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), DeadLetters: sink}
+	timestamp, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	e := &Event{timestamp, map[string]interface{}{}}
+	assert.Error(t, (&Table{Client: c, Name: "t0"}).InsertEvent("o0", e))
+	status = http.StatusNotFound
+	assert.Error(t, (&Table{Client: c, Name: "t0"}).InsertEvent("o0", e))
+	assert.Equal(t, len(sink.letters), 0)
+
+	letters := []*DeadLetter{{Table: "t0", ID: "o0", Event: e}, {Table: "t0", ID: "o1", Event: e}}
+	failed, err := c.Redrive(letters)
+	assert.Equal(t, err.(*APIError).StatusCode, http.StatusNotFound)
+	assert.Equal(t, failed, letters)
+}
+
+// memoryDeadLetterSink holds dead letters in memory.
+type memoryDeadLetterSink struct {
+	letters []*DeadLetter
+}
+
+func (s *memoryDeadLetterSink) WriteDeadLetter(d *DeadLetter) error {
+	s.letters = append(s.letters, d)
+	return nil
+}
@@ -0,0 +1,228 @@
+package sky
+
+import (
+	"container/list"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultDedupeSize is the number of events remembered by a deduper if no
+// size is given.
+const DefaultDedupeSize = 100000
+
+// Deduper remembers a bounded window of recently inserted events so that
+// redelivered events can be skipped. Sky merges events on the same object and
+// timestamp so an exact duplicate is skipped while an event with the same
+// object and timestamp but different data is reported as a conflict and still
+// inserted. Events are only remembered once the server has accepted them. The
+// zero value remembers up to DefaultDedupeSize events.
+type Deduper struct {
+	// OnConflict is called when an event has the same table, object and
+	// timestamp as a recent event but different data.
+	OnConflict func(table string, id string, e *Event)
+
+	mutex   sync.Mutex
+	size    int
+	entries map[dedupeKey]*list.Element
+	list    *list.List
+}
+
+type dedupeKey struct {
+	Table     string `json:"table"`
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type dedupeEntry struct {
+	Key  dedupeKey `json:"key"`
+	Hash uint64    `json:"hash"`
+}
+
+// NewDeduper creates a deduper that remembers up to size events. If size is
+// not positive then DefaultDedupeSize is used.
+func NewDeduper(size int) *Deduper {
+	d := &Deduper{size: size}
+	d.init()
+	return d
+}
+
+// init sets the defaults of a zero value deduper. The mutex must be held
+// unless the deduper is not shared yet.
+func (d *Deduper) init() {
+	if d.size <= 0 {
+		d.size = DefaultDedupeSize
+	}
+	if d.entries == nil {
+		d.entries = make(map[dedupeKey]*list.Element)
+		d.list = list.New()
+	}
+}
+
+// pending returns a deduper for the events of a single batch so that
+// duplicates within the batch are skipped before any of them are accepted. A
+// nil deduper returns nil.
+func (d *Deduper) pending(size int) *Deduper {
+	if d == nil {
+		return nil
+	}
+	return &Deduper{OnConflict: d.OnConflict, size: size}
+}
+
+// Duplicate returns true if the event is an exact duplicate of a recent event.
+// If a recent event has the same key but different data then the conflict is
+// reported and false is returned. A nil deduper never reports duplicates.
+func (d *Deduper) Duplicate(table string, id string, e *Event) bool {
+	if d == nil || e == nil {
+		return false
+	}
+	key := newDedupeKey(table, id, e)
+	hash := hashEventData(e.Data)
+
+	d.mutex.Lock()
+	d.init()
+	elem, ok := d.entries[key]
+	if ok && elem.Value.(*dedupeEntry).Hash == hash {
+		d.list.MoveToFront(elem)
+		d.mutex.Unlock()
+		return true
+	}
+	d.mutex.Unlock()
+
+	if ok && d.OnConflict != nil {
+		d.OnConflict(table, id, e)
+	}
+	return false
+}
+
+// Remember adds an event to the window after it has been inserted. An event
+// with the same key replaces the earlier one since the server merges them.
+func (d *Deduper) Remember(table string, id string, e *Event) {
+	if d == nil || e == nil {
+		return
+	}
+	key := newDedupeKey(table, id, e)
+	hash := hashEventData(e.Data)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.init()
+	if elem, ok := d.entries[key]; ok {
+		elem.Value.(*dedupeEntry).Hash = hash
+		d.list.MoveToFront(elem)
+		return
+	}
+	d.add(&dedupeEntry{key, hash})
+}
+
+// Len returns the number of events in the window.
+func (d *Deduper) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.init()
+	return d.list.Len()
+}
+
+// newDedupeKey returns the key of an event. The timestamp is truncated to the
+// server's precision since events that differ by less are merged.
+func newDedupeKey(table string, id string, e *Event) dedupeKey {
+	return dedupeKey{table, id, e.Timestamp.Truncate(TimestampPrecision).UnixNano()}
+}
+
+// add inserts an entry at the front of the window and evicts the oldest
+// entries if the window is full.
+func (d *Deduper) add(entry *dedupeEntry) {
+	d.entries[entry.Key] = d.list.PushFront(entry)
+	for d.list.Len() > d.size {
+		elem := d.list.Back()
+		d.list.Remove(elem)
+		delete(d.entries, elem.Value.(*dedupeEntry).Key)
+	}
+}
+
+// Save writes the window to a writer, oldest entries first.
+func (d *Deduper) Save(w io.Writer) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.init()
+	encoder := json.NewEncoder(w)
+	for elem := d.list.Back(); elem != nil; elem = elem.Prev() {
+		if err := encoder.Encode(elem.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads a window previously written with Save and adds it to the deduper.
+func (d *Deduper) Load(r io.Reader) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.init()
+	decoder := json.NewDecoder(r)
+	for {
+		entry := &dedupeEntry{}
+		if err := decoder.Decode(entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if elem, ok := d.entries[entry.Key]; ok {
+			d.list.Remove(elem)
+		}
+		d.add(entry)
+	}
+}
+
+// SaveFile writes the window to a file, replacing it if it exists. The window
+// is written to a temporary file first so that a crash never leaves a
+// partially written file behind.
+func (d *Deduper) SaveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := d.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile reads a window from a file. A missing file is not an error.
+func (d *Deduper) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.Load(f)
+}
+
+// hashEventData returns a hash of the event data. Map keys are encoded in
+// sorted order so equal data always produces the same hash.
+func hashEventData(data map[string]interface{}) uint64 {
+	h := fnv.New64a()
+	json.NewEncoder(h).Encode(data)
+	return h.Sum64()
+}
+
+// dedupeRecord is a streamed event that is remembered once it is flushed.
+type dedupeRecord struct {
+	table string
+	id    string
+	event *Event
+}
@@ -0,0 +1,132 @@
+package sky
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that exact duplicates are skipped and conflicts are reported.
+func TestDeduperDuplicate(t *testing.T) {
+	var conflicts int
+	d := NewDeduper(2)
+	d.OnConflict = func(table string, id string, e *Event) { conflicts++ }
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	t1, _ := ParseTimestamp("1970-01-01T00:00:01Z")
+	t2, _ := ParseTimestamp("1970-01-01T00:00:02Z")
+
+	assert.False(t, d.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": 1}}))
+	d.Remember("t", "o0", &Event{t0, map[string]interface{}{"a": 1}})
+	assert.True(t, d.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": 1}}))
+	assert.False(t, d.Duplicate("t", "o1", &Event{t0, map[string]interface{}{"a": 1}}))
+
+	// A conflict that is not inserted does not replace the earlier event.
+	assert.False(t, d.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": 2}}))
+	assert.Equal(t, conflicts, 1)
+	assert.True(t, d.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": 1}}))
+
+	// Timestamps are compared at the server's precision.
+	assert.True(t, d.Duplicate("t", "o0", &Event{t0.Add(time.Nanosecond), map[string]interface{}{"a": 1}}))
+
+	// Adding more events evicts the oldest entries.
+	d.Remember("t", "o0", &Event{t1, map[string]interface{}{}})
+	d.Remember("t", "o0", &Event{t2, map[string]interface{}{}})
+	assert.Equal(t, d.Len(), 2)
+	assert.False(t, d.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": 1}}))
+
+	// A nil deduper never reports duplicates.
+	var nilDeduper *Deduper
+	assert.False(t, nilDeduper.Duplicate("t", "o0", &Event{t0, nil}))
+
+	// A zero value deduper uses the default size.
+	zero := &Deduper{}
+	assert.False(t, zero.Duplicate("t", "o0", &Event{t0, nil}))
+	zero.Remember("t", "o0", &Event{t0, nil})
+	zero.Remember("t", "o0", &Event{t1, nil})
+	assert.True(t, zero.Duplicate("t", "o0", &Event{t0, nil}))
+	assert.Equal(t, zero.Len(), 2)
+}
+
+// Ensure that a window can be saved and loaded.
+func TestDeduperSaveLoad(t *testing.T) {
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	d := NewDeduper(10)
+	d.Remember("t", "o0", &Event{t0, map[string]interface{}{"a": "x"}})
+	d.Remember("t", "o1", &Event{t0, map[string]interface{}{"a": "y"}})
+
+	var buf bytes.Buffer
+	assert.NoError(t, d.Save(&buf))
+	other := NewDeduper(10)
+	assert.NoError(t, other.Load(&buf))
+	assert.Equal(t, other.Len(), 2)
+	assert.True(t, other.Duplicate("t", "o0", &Event{t0, map[string]interface{}{"a": "x"}}))
+	assert.True(t, other.Duplicate("t", "o1", &Event{t0, map[string]interface{}{"a": "y"}}))
+
+	// Files are replaced as a whole.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dedupe.ndjson")
+	assert.NoError(t, os.WriteFile(path, []byte("garbage"), 0600))
+	assert.NoError(t, d.SaveFile(path))
+	other = NewDeduper(10)
+	assert.NoError(t, other.LoadFile(path))
+	assert.Equal(t, other.Len(), 2)
+	entries, _ := os.ReadDir(dir)
+	assert.Equal(t, len(entries), 1)
+}
+
+// Ensure that failed inserts are not remembered.
+func TestTableInsertEventDedupe(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), Deduper: NewDeduper(10)}
+	table := &Table{Client: c, Name: "t0"}
+	e := &Event{time.Unix(0, 0), map[string]interface{}{"a": 1}}
+	assert.Error(t, table.InsertEvent("o0", e))
+	assert.NoError(t, table.InsertEvent("o0", e))
+	assert.NoError(t, table.InsertEvent("o0", e))
+	assert.Equal(t, requests, 2)
+}
+
+// Ensure that duplicates within a single batch are only sent once.
+func TestTableInsertBatchDedupe(t *testing.T) {
+	var lines int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0"}`))
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		lines += bytes.Count(b, []byte("\n"))
+	}))
+	defer server.Close()
+
+	var conflicts int
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), Deduper: NewDeduper(10), BatchConcurrency: 1}
+	c.Deduper.OnConflict = func(table string, id string, e *Event) { conflicts++ }
+	table := &Table{Client: c, Name: "t0"}
+	e := &Event{time.Unix(0, 0), map[string]interface{}{"a": 1}}
+	assert.NoError(t, table.InsertBatch([]ObjectEvent{
+		{ID: "o0", Event: e},
+		{ID: "o0", Event: e},
+		{ID: "o0", Event: &Event{time.Unix(0, 0), map[string]interface{}{"a": 2}}},
+		{ID: "o1", Event: e},
+	}))
+	assert.Equal(t, lines, 3)
+	assert.Equal(t, conflicts, 1)
+	assert.Equal(t, c.Deduper.Len(), 2)
+}
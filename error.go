@@ -2,6 +2,7 @@ package sky
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -29,4 +30,52 @@ var (
 
 	// ErrQueryRequired is returned when a blank query string is used.
 	ErrQueryRequired = errors.New("query required")
+
+	// ErrFunnelStepsRequired is returned when a funnel has no steps.
+	ErrFunnelStepsRequired = errors.New("funnel steps required")
+
+	// ErrInvalidPropertyName is returned when a property name cannot be used
+	// in a generated query.
+	ErrInvalidPropertyName = errors.New("invalid property name")
+
+	// ErrInvalidOperator is returned when a funnel step uses an unknown
+	// comparison operator.
+	ErrInvalidOperator = errors.New("invalid operator")
+
+	// ErrRetentionBucketRequired is returned when a retention bucket is
+	// shorter than one second.
+	ErrRetentionBucketRequired = errors.New("retention bucket required")
+
+	// ErrSessionTimeoutRequired is returned when a session idle timeout is
+	// shorter than one second.
+	ErrSessionTimeoutRequired = errors.New("session timeout required")
+
+	// ErrPathDepthRequired is returned when a path depth is less than one.
+	ErrPathDepthRequired = errors.New("path depth required")
+
+	// ErrInvalidBackup is returned when a backup archive is malformed or
+	// truncated.
+	ErrInvalidBackup = errors.New("invalid backup")
+
+	// ErrBackupChecksum is returned when a backup archive's contents do not
+	// match its checksum.
+	ErrBackupChecksum = errors.New("backup checksum mismatch")
 )
+
+// APIError is returned when the server responds to a request with a
+// non-200 status code.
+type APIError struct {
+	StatusCode int    `json:"status"`
+	Message    string `json:"message,omitempty"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+}
+
+// Error returns the server's message or a generic description of the failed
+// request if the server did not send one.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%d error: %s %s", e.StatusCode, e.Method, e.URL)
+}
@@ -1,7 +1,9 @@
 package sky
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -47,3 +49,101 @@ func (e *Event) Deserialize(obj map[string]interface{}) error {
 
 	return nil
 }
+
+// Int returns the value of an integer property. Whole floats, such as JSON
+// numbers decoded without a schema, are converted.
+func (e *Event) Int(name string) (int64, bool) {
+	switch v := e.Data[name].(type) {
+	case int64:
+		return v, true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int64(f), true
+		}
+	case int:
+		return int64(v), true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// Float returns the value of a numeric property.
+func (e *Event) Float(name string) (float64, bool) {
+	switch v := e.Data[name].(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// String returns the value of a string or factor property.
+func (e *Event) String(name string) (string, bool) {
+	v, ok := e.Data[name].(string)
+	return v, ok
+}
+
+// Bool returns the value of a boolean property.
+func (e *Event) Bool(name string) (bool, bool) {
+	v, ok := e.Data[name].(bool)
+	return v, ok
+}
+
+// Time returns the value of a property holding a time. Strings are parsed as
+// ISO8601 timestamps and numbers as epochs.
+func (e *Event) Time(name string) (time.Time, bool) {
+	switch v := e.Data[name].(type) {
+	case nil, bool:
+		return time.Time{}, false
+	case time.Time:
+		return v, true
+	case string:
+		timestamp, err := ParseTimestamp(v)
+		return timestamp, err == nil
+	default:
+		timestamp, err := (&TimestampParser{}).ParseValue(v)
+		return timestamp, err == nil
+	}
+}
+
+// Convert converts the event's data to the Go types of the given properties.
+// Integer properties are converted to int64 and float properties to float64.
+// Values decoded as json.Number are converted from their text so integers
+// keep their full precision, and numbers of other properties become float64.
+// Values that cannot be converted are left unchanged.
+func (e *Event) Convert(properties []*Property) {
+	for _, p := range properties {
+		if _, ok := e.Data[p.Name]; !ok {
+			continue
+		}
+		switch p.DataType {
+		case Integer:
+			if v, ok := e.Int(p.Name); ok {
+				e.Data[p.Name] = v
+			}
+		case Float:
+			if v, ok := e.Float(p.Name); ok {
+				e.Data[p.Name] = v
+			}
+		}
+	}
+	for k, v := range e.Data {
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				e.Data[k] = f
+			}
+		}
+	}
+}
@@ -2,21 +2,31 @@ package sky
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"time"
 )
 
 // Stream maintains an open connection to the database to send events in bulk.
 type Stream struct {
 	Client  *Client
+	ctx     context.Context
+	span    Span
+	path    string
 	header  []byte
 	encoder *json.Encoder
+	counter *countWriter
 	chunker *chunkWriter
 	buffer  *bufio.Writer
 	conn    net.Conn
+	events  int
+
+	// unflushed are events remembered by the deduper after the next flush.
+	unflushed []dedupeRecord
 }
 
 // EventStream is a table-less stream.
@@ -31,15 +41,38 @@ type TableEventStream struct {
 }
 
 func NewTableEventStream(c *Client, t *Table) (*TableEventStream, error) {
-	header := fmt.Sprintf("PATCH /tables/%s/events HTTP/1.0\r\nHost: %s\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n", t.Name, c.Host)
-	s := &TableEventStream{&Stream{Client: c, header: []byte(header)}, t}
-	return s, s.Reconnect()
+	return NewTableEventStreamContext(context.Background(), c, t)
+}
+
+// NewTableEventStreamContext opens a stream to a table. If the client has a
+// tracer then a span is started for the life of the stream and its trace
+// context is sent in the stream's request header.
+func NewTableEventStreamContext(ctx context.Context, c *Client, t *Table) (*TableEventStream, error) {
+	s := &TableEventStream{newStream(ctx, c, fmt.Sprintf("/tables/%s/events", t.Name), Attribute{"sky.table", t.Name}), t}
+	return s, s.connect()
 }
 
 func NewEventStream(c *Client) (*EventStream, error) {
-	header := fmt.Sprintf("PATCH /events HTTP/1.0\r\nHost: %s\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n", c.Host)
-	s := &EventStream{&Stream{Client: c, header: []byte(header)}}
-	return s, s.Reconnect()
+	return NewEventStreamContext(context.Background(), c)
+}
+
+// NewEventStreamContext opens a table-less stream. If the client has a tracer
+// then a span is started for the life of the stream and its trace context is
+// sent in the stream's request header.
+func NewEventStreamContext(ctx context.Context, c *Client) (*EventStream, error) {
+	s := &EventStream{newStream(ctx, c, "/events")}
+	return s, s.connect()
+}
+
+// newStream creates an unconnected stream for a request path.
+func newStream(ctx context.Context, c *Client, path string, attrs ...Attribute) *Stream {
+	ctx, span := c.startSpan(ctx, "sky.stream", append(attrs, Attribute{"http.path", path})...)
+	header := fmt.Sprintf("PATCH %s HTTP/1.0\r\nHost: %s\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n", path, c.Host)
+	if traceParent := span.TraceParent(); traceParent != "" {
+		header += fmt.Sprintf("%s: %s\r\n", TraceParentHeader, traceParent)
+	}
+	header += "\r\n"
+	return &Stream{Client: c, ctx: ctx, span: span, path: path, header: []byte(header)}
 }
 
 // AddEvent sends an event through the stream.
@@ -51,13 +84,22 @@ func (s *TableEventStream) InsertEvent(id string, event *Event) error {
 		return errors.New("Event required")
 	}
 
+	// Skip recently inserted duplicates.
+	if s.Client.Deduper.Duplicate(s.table.Name, id, event) {
+		return nil
+	}
+
 	// Attach the object identifier at the root of the event.
 	data := event.Serialize()
 	data["id"] = id
 
 	// Encode the serialized data into the stream.
-	s.table.InsertEvent(id, event)
-	return s.encoder.Encode(data)
+	if err := s.encoder.Encode(data); err != nil {
+		s.Client.logger().Warn("sky: dropped event", "table", s.table.Name, "id", id, "error", err)
+		return err
+	}
+	s.sent(s.table.Name, id, event)
+	return nil
 }
 
 // InsertEvent sends an event through the stream.
@@ -72,23 +114,57 @@ func (s *EventStream) InsertEvent(t *Table, id string, event *Event) error {
 		return errors.New("Event required")
 	}
 
+	// Skip recently inserted duplicates.
+	if s.Client.Deduper.Duplicate(t.Name, id, event) {
+		return nil
+	}
+
 	// Attach the object identifier at the root of the event.
 	data := event.Serialize()
 	data["id"] = id
 	data["table"] = t.Name
 
 	// Encode the serialized data into the stream.
-	t.InsertEvent(id, event)
-	return s.encoder.Encode(data)
+	if err := s.encoder.Encode(data); err != nil {
+		s.Client.logger().Warn("sky: dropped event", "table", t.Name, "id", id, "error", err)
+		return err
+	}
+	s.sent(t.Name, id, event)
+	return nil
+}
+
+// sent records an event that was written to the stream's buffer.
+func (s *Stream) sent(table string, id string, event *Event) {
+	s.events++
+	if s.Client.Deduper != nil {
+		s.unflushed = append(s.unflushed, dedupeRecord{table, id, event})
+	}
 }
 
-// Flush sends any buffered events to the server.
+// Flush sends any buffered events to the server. Events are remembered by the
+// client's deduper once they are flushed.
 func (s *Stream) Flush() error {
-	return s.buffer.Flush()
+	t := time.Now()
+	_, span := s.Client.startSpan(s.ctx, "sky.stream.flush", Attribute{"http.path", s.path}, Attribute{"sky.events", s.events}, Attribute{"sky.bytes", s.counter.n})
+	err := s.buffer.Flush()
+	span.End(err)
+	s.Client.notify(StreamEvent{Type: StreamFlush, Path: s.path, Events: s.events, Bytes: s.counter.n, Duration: time.Since(t), Err: err})
+	if err == nil {
+		for _, r := range s.unflushed {
+			s.Client.Deduper.Remember(r.table, r.id, r.event)
+		}
+	}
+	s.events, s.counter.n, s.unflushed = 0, 0, nil
+	return err
 }
 
 // Close closes the event stream.
-func (s *Stream) Close() error {
+func (s *Stream) Close() (err error) {
+	t := time.Now()
+	defer func() {
+		s.span.End(err)
+		s.Client.notify(StreamEvent{Type: StreamClose, Path: s.path, Duration: time.Since(t), Err: err})
+	}()
 	defer s.conn.Close()
 
 	// Flush any buffered events
@@ -105,11 +181,31 @@ func (s *Stream) Close() error {
 	return nil
 }
 
+// connect opens the stream's first connection. The stream's span is ended if
+// the connection fails since the stream will not be closed.
+func (s *Stream) connect() error {
+	err := s.Reconnect()
+	if err != nil {
+		s.span.End(err)
+	}
+	return err
+}
+
 // Reconnect attempts to reconnect the event stream with the server.
-func (s *Stream) Reconnect() error {
+func (s *Stream) Reconnect() (err error) {
+	t, typ := time.Now(), StreamConnect
+	defer func() {
+		if typ == StreamReconnect && err != nil {
+			s.Client.logger().Warn("sky: stream reconnect failed", "path", s.path, "error", err)
+		} else if typ == StreamReconnect {
+			s.Client.logger().Info("sky: stream reconnect", "path", s.path)
+		}
+		s.Client.notify(StreamEvent{Type: typ, Path: s.path, Duration: time.Since(t), Err: err})
+	}()
 
 	// Close the existing connection
 	if s.conn != nil {
+		typ = StreamReconnect
 		s.conn.Close()
 		s.conn = nil
 	}
@@ -130,10 +226,24 @@ func (s *Stream) Reconnect() error {
 	s.conn = conn
 	s.chunker = &chunkWriter{conn}
 	s.buffer = bufio.NewWriter(s.chunker)
-	s.encoder = json.NewEncoder(s.buffer)
+	s.counter = &countWriter{w: s.buffer}
+	s.encoder = json.NewEncoder(s.counter)
+	s.events, s.unflushed = 0, nil
 	return nil
 }
 
+// countWriter is an io.Writer that counts the bytes written through it.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
 // chunkWriter is an io.Writer that will emit any writes in HTTP chunk format
 type chunkWriter struct {
 	w io.Writer
@@ -0,0 +1,86 @@
+package sky
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that event values can be read with typed accessors.
+func TestEventAccessors(t *testing.T) {
+	e := &Event{Data: map[string]interface{}{
+		"count":   float64(12),
+		"ratio":   1.5,
+		"action":  "signup",
+		"active":  true,
+		"created": "2013-11-12T00:00:00Z",
+		"seen":    float64(1384214400),
+	}}
+
+	n, ok := e.Int("count")
+	assert.True(t, ok)
+	assert.Equal(t, n, int64(12))
+	_, ok = e.Int("ratio")
+	assert.False(t, ok)
+
+	f, ok := e.Float("count")
+	assert.True(t, ok)
+	assert.Equal(t, f, float64(12))
+
+	s, ok := e.String("action")
+	assert.True(t, ok)
+	assert.Equal(t, s, "signup")
+	_, ok = e.String("count")
+	assert.False(t, ok)
+
+	b, ok := e.Bool("active")
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = e.Bool("missing")
+	assert.False(t, ok)
+
+	expected := time.Date(2013, 11, 12, 0, 0, 0, 0, time.UTC)
+	tm, ok := e.Time("created")
+	assert.True(t, ok)
+	assert.True(t, tm.Equal(expected))
+	tm, ok = e.Time("seen")
+	assert.True(t, ok)
+	assert.True(t, tm.Equal(expected))
+	_, ok = e.Time("active")
+	assert.False(t, ok)
+}
+
+// Ensure that typed events convert integer properties to int64 without losing
+// precision.
+func TestTableEventsTyped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tables/t0/properties":
+			w.Write([]byte(`[{"name":"count","dataType":"integer"},{"name":"big","dataType":"integer"},{"name":"ratio","dataType":"float"}]`))
+		case "/tables/t0/objects/o0/events":
+			w.Write([]byte(`[{"timestamp":"1970-01-01T00:00:00Z","data":{"count":3,"big":9007199254740993,"ratio":2,"other":1.5,"action":"a"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	table := &Table{Client: c, Name: "t0"}
+	events, err := table.Events("o0")
+	assert.NoError(t, err)
+	assert.Equal(t, events[0].Data["count"], float64(3))
+
+	c.TypedEvents = true
+	events, err = table.Events("o0")
+	assert.NoError(t, err)
+	assert.Equal(t, events[0].Data["count"], int64(3))
+	assert.Equal(t, events[0].Data["big"], int64(9007199254740993))
+	assert.Equal(t, events[0].Data["ratio"], float64(2))
+	assert.Equal(t, events[0].Data["other"], 1.5)
+	assert.Equal(t, events[0].Data["action"], "a")
+}
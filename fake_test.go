@@ -0,0 +1,284 @@
+package sky
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer is an in-memory Sky server for tests that need to read back the
+// data that they write.
+type fakeServer struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	tables  map[string]*fakeTable
+	streams int
+	closed  *sync.Cond
+}
+
+type fakeTable struct {
+	properties []*Property
+	objects    map[string]map[string]map[string]interface{}
+}
+
+// newFakeServer starts a fake server and returns a client connected to it.
+func newFakeServer(t *testing.T) (*fakeServer, *Client) {
+	s := &fakeServer{tables: map[string]*fakeTable{}}
+	s.closed = sync.NewCond(&s.mutex)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s, &Client{Host: strings.TrimPrefix(s.URL, "http://")}
+}
+
+// table creates a table on the server with a set of properties.
+func (s *fakeServer) table(name string, properties ...*Property) *fakeTable {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t := &fakeTable{properties: properties, objects: map[string]map[string]map[string]interface{}{}}
+	s.tables[name] = t
+	return t
+}
+
+// insert adds an event to a table on the server.
+func (t *fakeTable) insert(id string, e *Event) {
+	events := t.objects[id]
+	if events == nil {
+		events = map[string]map[string]interface{}{}
+		t.objects[id] = events
+	}
+	data := events[FormatTimestamp(e.Timestamp)]
+	if data == nil {
+		data = map[string]interface{}{}
+		events[FormatTimestamp(e.Timestamp)] = data
+	}
+	for k, v := range e.Data {
+		data[k] = v
+	}
+}
+
+// events returns an object's events in timestamp order.
+func (t *fakeTable) events(id string) []map[string]interface{} {
+	var timestamps []string
+	for timestamp := range t.objects[id] {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Strings(timestamps)
+	output := []map[string]interface{}{}
+	for _, timestamp := range timestamps {
+		output = append(output, map[string]interface{}{"timestamp": timestamp, "data": t.objects[id][timestamp]})
+	}
+	return output
+}
+
+// waitStreams waits until the server has read n streams to the end.
+func (s *fakeServer) waitStreams(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.streams < n {
+		s.closed.Wait()
+	}
+}
+
+// serveStream inserts the events sent through a stream. Streams use chunked
+// HTTP/1.0 requests so the body is read from the hijacked connection.
+func (s *fakeServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		s.mutex.Lock()
+		s.streams++
+		s.closed.Broadcast()
+		s.mutex.Unlock()
+	}()
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	scanner := bufio.NewScanner(httputil.NewChunkedReader(rw))
+	for scanner.Scan() {
+		var m map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		name, _ := m["table"].(string)
+		if len(segments) == 3 {
+			name = segments[1]
+		}
+		e := &Event{}
+		e.Deserialize(m)
+		id, _ := m["id"].(string)
+		s.mutex.Lock()
+		if t := s.tables[name]; t != nil {
+			t.insert(id, e)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Proto == "HTTP/1.0" {
+		s.serveStream(w, r)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string]interface{}{"message": "not found"})
+	}
+
+	if r.URL.Path == "/" {
+		reply(map[string]interface{}{"version": Version})
+		return
+	} else if segments[0] != "tables" {
+		notFound()
+		return
+	}
+
+	// Table collection.
+	if len(segments) == 1 {
+		switch r.Method {
+		case "GET":
+			var names []string
+			for name := range s.tables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			tables := []map[string]interface{}{}
+			for _, name := range names {
+				tables = append(tables, map[string]interface{}{"name": name})
+			}
+			reply(tables)
+		case "POST":
+			var m map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&m)
+			name, _ := m["name"].(string)
+			if _, ok := s.tables[name]; ok {
+				w.WriteHeader(http.StatusBadRequest)
+				reply(map[string]interface{}{"message": "table already exists"})
+				return
+			}
+			s.tables[name] = &fakeTable{objects: map[string]map[string]map[string]interface{}{}}
+			reply(m)
+		}
+		return
+	}
+
+	t := s.tables[segments[1]]
+	if t == nil {
+		notFound()
+		return
+	}
+	switch {
+	case len(segments) == 2 && r.Method == "GET":
+		reply(map[string]interface{}{"name": segments[1]})
+	case len(segments) == 2 && r.Method == "DELETE":
+		delete(s.tables, segments[1])
+
+	case len(segments) == 3 && segments[2] == "properties" && r.Method == "GET":
+		reply(t.properties)
+	case len(segments) == 3 && segments[2] == "properties" && r.Method == "POST":
+		p := &Property{}
+		json.NewDecoder(r.Body).Decode(p)
+		for _, other := range t.properties {
+			if other.Name == p.Name {
+				w.WriteHeader(http.StatusBadRequest)
+				reply(map[string]interface{}{"message": "property already exists"})
+				return
+			}
+		}
+		t.properties = append(t.properties, p)
+		reply(p)
+	case len(segments) == 4 && segments[2] == "properties":
+		for i, p := range t.properties {
+			if p.Name != segments[3] {
+				continue
+			}
+			switch r.Method {
+			case "GET":
+				reply(p)
+			case "PATCH":
+				other := &Property{}
+				json.NewDecoder(r.Body).Decode(other)
+				for _, events := range t.objects {
+					for _, data := range events {
+						if v, ok := data[p.Name]; ok {
+							delete(data, p.Name)
+							data[other.Name] = v
+						}
+					}
+				}
+				p.Name = other.Name
+				reply(p)
+			case "DELETE":
+				t.properties = append(t.properties[:i:i], t.properties[i+1:]...)
+				for _, events := range t.objects {
+					for _, data := range events {
+						delete(data, p.Name)
+					}
+				}
+			}
+			return
+		}
+		notFound()
+
+	case len(segments) == 3 && segments[2] == "keys":
+		keys := []string{}
+		for id := range t.objects {
+			keys = append(keys, id)
+		}
+		sort.Strings(keys)
+		reply(keys)
+	case len(segments) == 3 && segments[2] == "events" && r.Method == "PATCH":
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var m map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &m)
+			e := &Event{}
+			e.Deserialize(m)
+			id, _ := m["id"].(string)
+			t.insert(id, e)
+		}
+
+	case len(segments) == 5 && segments[2] == "objects" && segments[4] == "events" && r.Method == "GET":
+		reply(t.events(segments[3]))
+	case len(segments) == 5 && segments[2] == "objects" && segments[4] == "events" && r.Method == "DELETE":
+		delete(t.objects, segments[3])
+	case len(segments) == 6 && segments[2] == "objects" && segments[4] == "events":
+		timestamp, _ := ParseTimestamp(segments[5])
+		key := FormatTimestamp(timestamp)
+		switch r.Method {
+		case "GET":
+			if data, ok := t.objects[segments[3]][key]; ok {
+				reply(map[string]interface{}{"timestamp": key, "data": data})
+			} else {
+				reply(map[string]interface{}{})
+			}
+		case "PUT", "PATCH":
+			var m map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&m)
+			e := &Event{}
+			e.Deserialize(m)
+			e.Timestamp = timestamp
+			if r.Method == "PUT" {
+				delete(t.objects[segments[3]], key)
+			}
+			t.insert(segments[3], e)
+		case "DELETE":
+			delete(t.objects[segments[3]], key)
+		}
+
+	default:
+		notFound()
+	}
+}
@@ -0,0 +1,238 @@
+package sky
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FunnelStep is a single step in a funnel. An event matches the step if the
+// property's value compares with Value using Op.
+type FunnelStep struct {
+	Property string
+	Op       string
+	Value    interface{}
+
+	// Within is the maximum number of events after the previous step that
+	// the step can occur in. If zero then the step can occur at any later
+	// event, up to the largest window a query can express. It is ignored
+	// for the first step.
+	Within int
+}
+
+// FunnelOptions are optional settings for Table.Funnel.
+type FunnelOptions struct {
+	// GroupBy is a property that the funnel is broken down by.
+	GroupBy string
+
+	// Session limits each funnel to a single session where sessions are
+	// delimited by this amount of idle time. Rounded up to seconds.
+	Session time.Duration
+}
+
+// maxFunnelWithin is the window of a step without a Within limit.
+const maxFunnelWithin = math.MaxInt32
+
+// FunnelResult is the number of objects reaching each step of a funnel.
+type FunnelResult struct {
+	Steps []*FunnelStepResult
+
+	// Groups holds a result for each value of the GroupBy property.
+	Groups map[string]*FunnelResult
+}
+
+// FunnelStepResult is the number of objects reaching a funnel step.
+type FunnelStepResult struct {
+	Count int
+
+	// Conversion is the fraction of the previous step that reached this step.
+	Conversion float64
+
+	// Overall is the fraction of the first step that reached this step.
+	Overall float64
+}
+
+// Funnel counts the objects that pass through each step of a funnel. Every
+// event matching the first step starts a pass through the funnel but each
+// object is counted at most once per step. When grouped, an object is counted in the group of the event that
+// reached the step.
+func (t *Table) Funnel(steps []*FunnelStep, opts *FunnelOptions) (*FunnelResult, error) {
+	if opts == nil {
+		opts = &FunnelOptions{}
+	}
+	q, err := FunnelQuery(steps, opts)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.Query(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.GroupBy == "" {
+		return newFunnelResult(output, len(steps)), nil
+	}
+	result := &FunnelResult{Groups: map[string]*FunnelResult{}}
+	groups, _ := output[opts.GroupBy].(map[string]interface{})
+	totals := map[string]interface{}{}
+	for key, v := range groups {
+		m, _ := v.(map[string]interface{})
+		result.Groups[key] = newFunnelResult(m, len(steps))
+		for i := range steps {
+			name := funnelStepAlias(i)
+			n, _ := totals[name].(float64)
+			f, _ := m[name].(float64)
+			totals[name] = n + f
+		}
+	}
+	result.Steps = newFunnelResult(totals, len(steps)).Steps
+	return result, nil
+}
+
+// FunnelQuery generates the SkyQL for a funnel. Each step is nested inside
+// the previous one and matches at the first event within its window. The
+// step's count is selected as "step0", "step1", etc. the first time an object
+// reaches it, which is tracked in the funnel_reached variable.
+func FunnelQuery(steps []*FunnelStep, opts *FunnelOptions) (string, error) {
+	if len(steps) == 0 {
+		return "", ErrFunnelStepsRequired
+	}
+	if opts == nil {
+		opts = &FunnelOptions{}
+	}
+
+	var groupBy string
+	if opts.GroupBy != "" {
+		if !isIdent(opts.GroupBy) {
+			return "", ErrInvalidPropertyName
+		}
+		groupBy = " GROUP BY " + opts.GroupBy
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("DECLARE funnel_reached AS INTEGER\n")
+	depth := 0
+	if opts.Session > 0 {
+		secs := int64((opts.Session + time.Second - 1) / time.Second)
+		fmt.Fprintf(&buf, "FOR EACH SESSION DELIMITED BY %d SECONDS\n", secs)
+		depth++
+	}
+	for i, step := range steps {
+		expr, err := step.expr()
+		if err != nil {
+			return "", err
+		}
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(&buf, "%sWHEN %s", indent, expr)
+		if i > 0 {
+			within := step.Within
+			if within <= 0 {
+				within = maxFunnelWithin
+			}
+			fmt.Fprintf(&buf, " WITHIN 1 .. %d STEPS", within)
+		}
+		fmt.Fprintf(&buf, " THEN\n")
+		fmt.Fprintf(&buf, "%s  WHEN funnel_reached == %d THEN\n", indent, i)
+		fmt.Fprintf(&buf, "%s    SET funnel_reached = %d\n", indent, i+1)
+		fmt.Fprintf(&buf, "%s    SELECT count() AS %s%s\n", indent, funnelStepAlias(i), groupBy)
+		fmt.Fprintf(&buf, "%s  END\n", indent)
+		depth++
+	}
+	for depth > 0 {
+		depth--
+		fmt.Fprintf(&buf, "%sEND\n", strings.Repeat("  ", depth))
+	}
+	return buf.String(), nil
+}
+
+// funnelOps are the comparison operators allowed in a funnel step.
+var funnelOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// expr returns the step's condition as a SkyQL expression.
+func (s *FunnelStep) expr() (string, error) {
+	if s.Property == "" {
+		return "", ErrPropertyNameRequired
+	} else if !isIdent(s.Property) {
+		return "", ErrInvalidPropertyName
+	}
+	op := s.Op
+	if op == "" {
+		op = "=="
+	} else if !funnelOps[op] {
+		return "", ErrInvalidOperator
+	}
+	value, err := queryLiteral(s.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", s.Property, op, value), nil
+}
+
+// isIdent returns true if s can be used as an identifier in a query.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, ch := range s {
+		if ch != '_' && !unicode.IsLetter(ch) && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func funnelStepAlias(i int) string {
+	return "step" + strconv.Itoa(i)
+}
+
+// newFunnelResult reads the step counts from a query result.
+func newFunnelResult(output map[string]interface{}, n int) *FunnelResult {
+	result := &FunnelResult{}
+	for i := 0; i < n; i++ {
+		count, _ := output[funnelStepAlias(i)].(float64)
+		step := &FunnelStepResult{Count: int(count)}
+		if i == 0 {
+			if step.Count > 0 {
+				step.Conversion, step.Overall = 1, 1
+			}
+		} else {
+			step.Conversion = ratio(step.Count, result.Steps[i-1].Count)
+			step.Overall = ratio(step.Count, result.Steps[0].Count)
+		}
+		result.Steps = append(result.Steps, step)
+	}
+	return result
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// queryLiteral formats a Go value as a SkyQL literal.
+func queryLiteral(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+		return `"` + r.Replace(v) + `"`, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid query value: %v", v)
+}
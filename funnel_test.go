@@ -0,0 +1,179 @@
+package sky_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/daemonchen/gosky/skyql"
+	"github.com/stretchr/testify/assert"
+)
+
+var analyticsProperties = []*sky.Property{
+	{Name: "gender", DataType: sky.Factor},
+	{Name: "action", Transient: true, DataType: sky.Factor},
+}
+
+// analyticsEvents returns events for three objects. Object o1 checks out in a
+// second session an hour after adding to the cart.
+func analyticsEvents() []sky.ObjectEvent {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	event := func(d time.Duration, data map[string]interface{}) *sky.Event {
+		return &sky.Event{Timestamp: t0.Add(d), Data: data}
+	}
+	return []sky.ObjectEvent{
+		{ID: "o0", Event: event(0, map[string]interface{}{"gender": "m", "action": "home"})},
+		{ID: "o0", Event: event(time.Minute, map[string]interface{}{"action": "cart"})},
+		{ID: "o0", Event: event(2*time.Minute, map[string]interface{}{"action": "checkout"})},
+		{ID: "o1", Event: event(0, map[string]interface{}{"gender": "f", "action": "home"})},
+		{ID: "o1", Event: event(time.Minute, map[string]interface{}{"action": "cart"})},
+		{ID: "o1", Event: event(time.Hour, map[string]interface{}{"action": "checkout"})},
+		{ID: "o2", Event: event(0, map[string]interface{}{"gender": "f", "action": "home"})},
+	}
+}
+
+// newQueryServer returns a server that evaluates queries against a fixed set
+// of events.
+func newQueryServer(t *testing.T, properties []*sky.Property, events []sky.ObjectEvent) (*httptest.Server, *sky.Table) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tables/t0/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		result, err := skyql.Evaluate(string(b), properties, events)
+		if !assert.NoError(t, err, string(b)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	return server, &sky.Table{Client: c, Name: "t0"}
+}
+
+// Ensure that a funnel counts each step and computes conversion rates.
+func TestTableFunnel(t *testing.T) {
+	server, table := newQueryServer(t, analyticsProperties, analyticsEvents())
+	defer server.Close()
+
+	steps := []*sky.FunnelStep{
+		{Property: "action", Value: "home"},
+		{Property: "action", Value: "cart", Within: 1},
+		{Property: "action", Value: "checkout"},
+	}
+	result, err := table.Funnel(steps, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(result.Steps), 3)
+	assert.Equal(t, result.Steps[0].Count, 3)
+	assert.Equal(t, result.Steps[1].Count, 2)
+	assert.Equal(t, result.Steps[2].Count, 2)
+	assert.InDelta(t, result.Steps[1].Conversion, 2.0/3.0, 0.0001)
+	assert.InDelta(t, result.Steps[2].Conversion, 1.0, 0.0001)
+	assert.InDelta(t, result.Steps[2].Overall, 2.0/3.0, 0.0001)
+
+	// Sessions end the funnel for o1 before checkout.
+	result, err = table.Funnel(steps, &sky.FunnelOptions{Session: 30 * time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, result.Steps[2].Count, 1)
+}
+
+// Ensure that a funnel can be broken down by a property.
+func TestTableFunnelGroupBy(t *testing.T) {
+	server, table := newQueryServer(t, analyticsProperties, analyticsEvents())
+	defer server.Close()
+
+	steps := []*sky.FunnelStep{
+		{Property: "action", Value: "home"},
+		{Property: "action", Value: "checkout"},
+	}
+	result, err := table.Funnel(steps, &sky.FunnelOptions{GroupBy: "gender"})
+	assert.NoError(t, err)
+	assert.Equal(t, result.Steps[0].Count, 3)
+	assert.Equal(t, result.Steps[1].Count, 2)
+	assert.Equal(t, result.Groups["f"].Steps[0].Count, 2)
+	assert.Equal(t, result.Groups["f"].Steps[1].Count, 1)
+	assert.InDelta(t, result.Groups["f"].Steps[1].Conversion, 0.5, 0.0001)
+	assert.Equal(t, result.Groups["m"].Steps[1].Count, 1)
+}
+
+// Ensure that an object is counted once per step even if it passes through
+// the funnel more than once or only completes a later pass.
+func TestTableFunnelDistinct(t *testing.T) {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	events := analyticsEvents()
+	for id, actions := range map[string][]string{
+		"o3": {"home", "cart", "home", "cart", "checkout"},
+		"o4": {"home", "checkout", "home", "cart", "checkout"},
+	} {
+		for i, action := range actions {
+			e := &sky.Event{Timestamp: t0.Add(time.Duration(i) * time.Minute), Data: map[string]interface{}{"action": action}}
+			events = append(events, sky.ObjectEvent{ID: id, Event: e})
+		}
+	}
+	server, table := newQueryServer(t, analyticsProperties, events)
+	defer server.Close()
+
+	result, err := table.Funnel([]*sky.FunnelStep{
+		{Property: "action", Value: "home"},
+		{Property: "action", Value: "cart", Within: 1},
+		{Property: "action", Value: "checkout"},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, result.Steps[0].Count, 5)
+	assert.Equal(t, result.Steps[1].Count, 4)
+	assert.Equal(t, result.Steps[2].Count, 4)
+}
+
+// Ensure that funnel queries are generated with nested conditions.
+func TestFunnelQuery(t *testing.T) {
+	q, err := sky.FunnelQuery([]*sky.FunnelStep{
+		{Property: "action", Value: `say "hi"`},
+		{Property: "price", Op: ">", Value: 10.0, Within: 2},
+		{Property: "action", Value: "buy"},
+	}, &sky.FunnelOptions{GroupBy: "gender", Session: 1500 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.Equal(t, q, ""+
+		"DECLARE funnel_reached AS INTEGER\n"+
+		"FOR EACH SESSION DELIMITED BY 2 SECONDS\n"+
+		"  WHEN action == \"say \\\"hi\\\"\" THEN\n"+
+		"    WHEN funnel_reached == 0 THEN\n"+
+		"      SET funnel_reached = 1\n"+
+		"      SELECT count() AS step0 GROUP BY gender\n"+
+		"    END\n"+
+		"    WHEN price > 10.0 WITHIN 1 .. 2 STEPS THEN\n"+
+		"      WHEN funnel_reached == 1 THEN\n"+
+		"        SET funnel_reached = 2\n"+
+		"        SELECT count() AS step1 GROUP BY gender\n"+
+		"      END\n"+
+		"      WHEN action == \"buy\" WITHIN 1 .. 2147483647 STEPS THEN\n"+
+		"        WHEN funnel_reached == 2 THEN\n"+
+		"          SET funnel_reached = 3\n"+
+		"          SELECT count() AS step2 GROUP BY gender\n"+
+		"        END\n"+
+		"      END\n"+
+		"    END\n"+
+		"  END\n"+
+		"END\n")
+}
+
+// Ensure that invalid funnels are rejected before a query is generated.
+func TestFunnelQueryInvalid(t *testing.T) {
+	_, err := sky.FunnelQuery(nil, nil)
+	assert.Equal(t, err, sky.ErrFunnelStepsRequired)
+	_, err = sky.FunnelQuery([]*sky.FunnelStep{{Property: "x", Value: []int{}}}, nil)
+	assert.Error(t, err)
+	_, err = sky.FunnelQuery([]*sky.FunnelStep{{Property: "x == 1 || y", Value: 1}}, nil)
+	assert.Equal(t, err, sky.ErrInvalidPropertyName)
+	_, err = sky.FunnelQuery([]*sky.FunnelStep{{Property: "1x", Value: 1}}, nil)
+	assert.Equal(t, err, sky.ErrInvalidPropertyName)
+	_, err = sky.FunnelQuery([]*sky.FunnelStep{{Property: "x", Op: "== 1 ||", Value: 1}}, nil)
+	assert.Equal(t, err, sky.ErrInvalidOperator)
+	_, err = sky.FunnelQuery([]*sky.FunnelStep{{Property: "x", Value: 1}}, &sky.FunnelOptions{GroupBy: "a b"})
+	assert.Equal(t, err, sky.ErrInvalidPropertyName)
+}
@@ -0,0 +1,211 @@
+package sky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPageSize is the number of items requested per page by iterators if
+// the client's PageSize is not set.
+const DefaultPageSize = 100
+
+// TableIterator iterates over the tables on a server in name order.
+type TableIterator struct {
+	pager
+	tables []*Table
+	table  *Table
+}
+
+// TableIterator returns an iterator over the tables whose names start with
+// prefix. Servers without paging are read in a single request.
+func (c *Client) TableIterator(prefix string) *TableIterator {
+	return &TableIterator{pager: pager{client: c, path: "/tables", prefix: prefix}}
+}
+
+// Next advances to the next table. Returns false when there are no more
+// tables or an error occurs.
+func (i *TableIterator) Next() bool {
+	for len(i.tables) == 0 {
+		var page []*Table
+		if !i.next(&page) {
+			return false
+		}
+		names := make([]string, len(page))
+		for j, t := range page {
+			names[j] = t.Name
+		}
+		for _, j := range i.filter(names) {
+			i.tables = append(i.tables, page[j])
+		}
+	}
+	i.table, i.tables = i.tables[0], i.tables[1:]
+	i.table.Client = i.client
+	return true
+}
+
+// Table returns the current table.
+func (i *TableIterator) Table() *Table { return i.table }
+
+// PropertyIterator iterates over the properties of a table in name order.
+type PropertyIterator struct {
+	pager
+	properties []*Property
+	property   *Property
+}
+
+// PropertyIterator returns an iterator over the table's properties whose
+// names start with prefix. Servers without paging are read in a single
+// request.
+func (t *Table) PropertyIterator(prefix string) *PropertyIterator {
+	i := &PropertyIterator{pager: pager{client: t.Client, path: fmt.Sprintf("/tables/%s/properties", t.Name), prefix: prefix}}
+	if t.Client == nil {
+		i.err = ErrClientRequired
+	}
+	return i
+}
+
+// Next advances to the next property. Returns false when there are no more
+// properties or an error occurs.
+func (i *PropertyIterator) Next() bool {
+	for len(i.properties) == 0 {
+		var page []*Property
+		if !i.next(&page) {
+			return false
+		}
+		names := make([]string, len(page))
+		for j, p := range page {
+			names[j] = p.Name
+		}
+		for _, j := range i.filter(names) {
+			i.properties = append(i.properties, page[j])
+		}
+	}
+	i.property, i.properties = i.properties[0], i.properties[1:]
+	return true
+}
+
+// Property returns the current property.
+func (i *PropertyIterator) Property() *Property { return i.property }
+
+// pager retrieves a list from the server one page at a time using the query
+// parameters described by CapabilityPaging.
+type pager struct {
+	client *Client
+	path   string
+	prefix string
+	size   int
+	after  string
+	paged  bool
+	done   bool
+	err    error
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (p *pager) Err() error { return p.err }
+
+// next decodes the next page into a slice pointer. Returns false when there
+// are no more pages or an error occurs.
+func (p *pager) next(page interface{}) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	var query url.Values
+	if p.paged = p.client.supports(CapabilityPaging); p.paged {
+		p.size = p.client.pageSize()
+		query = url.Values{"limit": {strconv.Itoa(p.size)}}
+		if p.prefix != "" {
+			query.Set("prefix", p.prefix)
+		}
+		if p.after != "" {
+			query.Set("after", p.after)
+		}
+	} else {
+		p.done = true
+	}
+	if p.err = p.client.getList(p.path, query, page); p.err != nil {
+		return false
+	}
+	return true
+}
+
+// filter returns the indexes of the names on a page that match the prefix in
+// name order and records the position of the next page. The next page starts
+// after the greatest name since a page may not be sorted. Paging stops if a
+// full page does not advance the position, which happens when a server
+// ignores the "after" parameter, and names at or before the position are
+// dropped since they have already been returned.
+func (p *pager) filter(names []string) []int {
+	after := p.after
+	if p.paged {
+		for _, name := range names {
+			if name > p.after {
+				p.after = name
+			}
+		}
+		if len(names) < p.size || p.after == after {
+			p.done = true
+		}
+	}
+	indexes := []int{}
+	for i, name := range names {
+		if strings.HasPrefix(name, p.prefix) && (!p.paged || after == "" || name > after) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool { return names[indexes[a]] < names[indexes[b]] })
+	return indexes
+}
+
+// pageSize returns the number of items requested per page by iterators.
+func (c *Client) pageSize() int {
+	if c.PageSize > 0 {
+		return c.PageSize
+	}
+	return DefaultPageSize
+}
+
+// cachedList is a response body held in the client's list cache.
+type cachedList struct {
+	body    json.RawMessage
+	expires time.Time
+}
+
+// getList retrieves a list from the server or from the list cache.
+func (c *Client) getList(path string, query url.Values, ret interface{}) error {
+	if c.ListCacheTTL <= 0 {
+		return c.sendQuery(context.Background(), "GET", path, query, nil, ret)
+	}
+
+	key := c.queryURL(path, query).RequestURI()
+	c.cacheMutex.Lock()
+	entry := c.listCache[key]
+	c.cacheMutex.Unlock()
+	if entry != nil && time.Now().Before(entry.expires) {
+		return json.Unmarshal(entry.body, ret)
+	}
+
+	var body json.RawMessage
+	if err := c.sendQuery(context.Background(), "GET", path, query, nil, &body); err != nil {
+		return err
+	}
+	c.cacheMutex.Lock()
+	if c.listCache == nil {
+		c.listCache = map[string]*cachedList{}
+	}
+	c.listCache[key] = &cachedList{body: body, expires: time.Now().Add(c.ListCacheTTL)}
+	c.cacheMutex.Unlock()
+	return json.Unmarshal(body, ret)
+}
+
+// clearListCache removes all cached lists.
+func (c *Client) clearListCache() {
+	c.cacheMutex.Lock()
+	c.listCache = nil
+	c.cacheMutex.Unlock()
+}
@@ -0,0 +1,158 @@
+package sky
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPagingServer returns a server with 26 tables that supports paging. Each
+// page is returned in reverse order.
+func newPagingServer(t *testing.T, requests *[]string) *httptest.Server {
+	var names []string
+	for i := 0; i < 25; i++ {
+		names = append(names, fmt.Sprintf("t%02d", i))
+	}
+	names = append(names, "u00")
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0","capabilities":["paging"]}`))
+			return
+		}
+		*requests = append(*requests, r.URL.RequestURI())
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		tables := []map[string]string{}
+		for _, name := range names {
+			if strings.HasPrefix(name, q.Get("prefix")) && name > q.Get("after") && len(tables) < limit {
+				tables = append([]map[string]string{{"name": name}}, tables...)
+			}
+		}
+		json.NewEncoder(w).Encode(tables)
+	}))
+}
+
+// Ensure that tables are read one page at a time.
+func TestClientTableIterator(t *testing.T) {
+	var requests []string
+	server := newPagingServer(t, &requests)
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), PageSize: 10}
+	i := c.TableIterator("t")
+	var names []string
+	for i.Next() {
+		assert.Equal(t, i.Table().Client, c)
+		names = append(names, i.Table().Name)
+	}
+	assert.NoError(t, i.Err())
+	assert.Equal(t, len(names), 25)
+	assert.Equal(t, names[0], "t00")
+	assert.Equal(t, names[24], "t24")
+	assert.Equal(t, requests, []string{
+		"/tables?limit=10&prefix=t",
+		"/tables?after=t09&limit=10&prefix=t",
+		"/tables?after=t19&limit=10&prefix=t",
+	})
+}
+
+// Ensure that all tables are listed one page at a time.
+func TestClientTablesPaging(t *testing.T) {
+	var requests []string
+	server := newPagingServer(t, &requests)
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), PageSize: 10}
+	tables, err := c.Tables()
+	assert.NoError(t, err)
+	if assert.Equal(t, len(tables), 26) {
+		assert.Equal(t, tables[25].Name, "u00")
+		assert.Equal(t, tables[25].Client, c)
+	}
+	assert.Equal(t, len(requests), 3)
+}
+
+// Ensure that iteration stops when a server ignores the position of the next
+// page.
+func TestClientTableIteratorIgnoredAfter(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0","capabilities":["paging"]}`))
+			return
+		}
+		requests++
+		w.Write([]byte(`[{"name":"t1"},{"name":"t0"}]`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), PageSize: 2}
+	tables, err := c.Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, len(tables), 2)
+	assert.Equal(t, tables[0].Name, "t0")
+	assert.Equal(t, requests, 2)
+}
+
+// Ensure that properties are filtered by prefix on servers without paging.
+func TestTablePropertyIteratorWithoutPaging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0","capabilities":[]}`))
+			return
+		}
+		assert.Equal(t, r.URL.RequestURI(), "/tables/t0/properties")
+		w.Write([]byte(`[{"name":"utm_source"},{"name":"action"},{"name":"utm_campaign"}]`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	i := (&Table{Client: c, Name: "t0"}).PropertyIterator("utm_")
+	var names []string
+	for i.Next() {
+		names = append(names, i.Property().Name)
+	}
+	assert.NoError(t, i.Err())
+	assert.Equal(t, names, []string{"utm_campaign", "utm_source"})
+
+	i = (&Table{Name: "t0"}).PropertyIterator("")
+	assert.False(t, i.Next())
+	assert.Equal(t, i.Err(), ErrClientRequired)
+}
+
+// Ensure that pages are cached until they expire or the tables change.
+func TestClientListCache(t *testing.T) {
+	var requests []string
+	server := newPagingServer(t, &requests)
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), ListCacheTTL: time.Hour}
+	count := func() int {
+		n := 0
+		for i := c.TableIterator("u"); i.Next(); {
+			n++
+		}
+		return n
+	}
+	assert.Equal(t, count(), 1)
+	assert.Equal(t, count(), 1)
+	assert.Equal(t, len(requests), 1)
+
+	c.clearListCache()
+	count()
+	assert.Equal(t, len(requests), 2)
+
+	c.ListCacheTTL = time.Nanosecond
+	c.clearListCache()
+	count()
+	time.Sleep(time.Millisecond)
+	count()
+	assert.Equal(t, len(requests), 4)
+}
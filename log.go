@@ -0,0 +1,91 @@
+package sky
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// redactedHeaders are headers whose values are never logged.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// logger returns the client's logger or a logger that discards everything.
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logResponse logs a summary of a request and its response at debug level.
+// If body logging is enabled then the response body is read into memory and
+// replaced so that it can still be decoded.
+func (c *Client) logResponse(req *http.Request, body []byte, resp *http.Response, err error, d time.Duration) {
+	logger := c.logger()
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	attrs := []interface{}{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.Duration("duration", d),
+		slog.Any("headers", redactHeaders(req.Header)),
+	}
+	if c.LogBodySize > 0 {
+		attrs = append(attrs, slog.String("request_body", truncate(body, c.LogBodySize)))
+	}
+	if err != nil {
+		logger.Debug("sky: request failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if c.LogBodySize > 0 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		attrs = append(attrs, slog.String("response_body", truncate(b, c.LogBodySize)))
+	}
+	logger.Debug("sky: request", attrs...)
+}
+
+// redactHeaders returns the first value of each header with the values of
+// authentication headers replaced.
+func redactHeaders(header http.Header) map[string]string {
+	m := make(map[string]string, len(header))
+	for k := range header {
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			m[k] = "REDACTED"
+		} else {
+			m[k] = header.Get(k)
+		}
+	}
+	return m
+}
+
+// truncate returns at most n bytes of b as a string.
+func truncate(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	return string(b[:n]) + "..."
+}
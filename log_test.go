@@ -0,0 +1,80 @@
+package sky
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that requests are logged at debug level with redacted headers and
+// truncated bodies.
+func TestClientLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"users"}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	c := &Client{
+		Host:        strings.TrimPrefix(server.URL, "http://"),
+		Logger:      slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		LogBodySize: 8,
+	}
+	c.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer secret")
+			return next.Do(req)
+		})
+	})
+	table, err := c.Table("users")
+	assert.NoError(t, err)
+	assert.Equal(t, table.Name, "users")
+
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, m["level"], "DEBUG")
+	assert.Equal(t, m["method"], "GET")
+	assert.Equal(t, m["status"], float64(200))
+	assert.Equal(t, m["headers"].(map[string]interface{})["Authorization"], "REDACTED")
+	assert.Equal(t, m["response_body"], `{"name":...`)
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+// Ensure that successful stream reconnects are logged at info level and
+// failed ones at warn level.
+func TestClientLoggerReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go ioutil.ReadAll(conn)
+		}
+	}()
+
+	var buf bytes.Buffer
+	c := &Client{Host: ln.Addr().String(), Logger: slog.New(slog.NewTextHandler(&buf, nil))}
+	stream, err := (&Table{Client: c, Name: "t0"}).Stream()
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Reconnect())
+	assert.Contains(t, buf.String(), `level=INFO msg="sky: stream reconnect" path=/tables/t0/events`)
+
+	buf.Reset()
+	ln.Close()
+	assert.Error(t, stream.Reconnect())
+	assert.Contains(t, buf.String(), `level=WARN msg="sky: stream reconnect failed" path=/tables/t0/events`)
+}
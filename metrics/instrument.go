@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+
+// Instrument records metrics for every request and stream of a client:
+//
+//	sky_requests_total{method,path,status}        requests by status class
+//	sky_request_duration_seconds{method,path}     request latency
+//	sky_stream_events_total{path}                 events flushed by streams
+//	sky_stream_bytes_total{path}                  bytes flushed by streams
+//	sky_stream_operations_total{path,type,result} stream connects, flushes, reconnects and closes
+//
+// Paths are reduced to templates such as "/tables/:name/query".
+func Instrument(c *sky.Client, r Registry) {
+	requests := r.Counter("sky_requests_total", "Total number of requests sent to the Sky server.")
+	latency := r.Histogram("sky_request_duration_seconds", "Latency of requests sent to the Sky server.", DefaultBuckets)
+	events := r.Counter("sky_stream_events_total", "Total number of events flushed through streams.")
+	bytes := r.Counter("sky_stream_bytes_total", "Total number of bytes flushed through streams.")
+	operations := r.Counter("sky_stream_operations_total", "Total number of stream lifecycle operations.")
+
+	c.Use(sky.TimingMiddleware(func(req *http.Request, resp *http.Response, err error, d time.Duration) {
+		path := PathTemplate(req.URL.Path)
+		status := "error"
+		if err == nil {
+			status = StatusClass(resp.StatusCode)
+		}
+		requests.Add(Labels{"method": req.Method, "path": path, "status": status}, 1)
+		latency.Observe(Labels{"method": req.Method, "path": path}, d.Seconds())
+	}))
+
+	c.Listen(func(e sky.StreamEvent) {
+		path := PathTemplate(e.Path)
+		result := "ok"
+		if e.Err != nil {
+			result = "error"
+		}
+		operations.Add(Labels{"path": path, "type": e.Type.String(), "result": result}, 1)
+		if e.Type == sky.StreamFlush && e.Err == nil {
+			events.Add(Labels{"path": path}, float64(e.Events))
+			bytes.Add(Labels{"path": path}, float64(e.Bytes))
+		}
+	})
+}
+
+// params maps a collection in a path to the name of the parameter after it.
+var params = map[string]string{
+	"tables":     ":name",
+	"properties": ":property",
+	"objects":    ":id",
+	"events":     ":timestamp",
+}
+
+// PathTemplate replaces the names, identifiers and timestamps in a request
+// path with parameters so that paths can be used as metric labels. For
+// example, "/tables/users/objects/bob/events" becomes
+// "/tables/:name/objects/:id/events".
+func PathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if param, ok := params[segments[i-1]]; ok && segments[i] != "" {
+			segments[i] = param
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// StatusClass returns the class of an HTTP status code, such as "2xx".
+func StatusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that paths are reduced to templates.
+func TestPathTemplate(t *testing.T) {
+	var tests = map[string]string{
+		"/ping":                                  "/ping",
+		"/tables":                                "/tables",
+		"/tables/users":                          "/tables/:name",
+		"/tables/users/query":                    "/tables/:name/query",
+		"/tables/users/properties/gender":        "/tables/:name/properties/:property",
+		"/tables/users/objects/bob/events":       "/tables/:name/objects/:id/events",
+		"/tables/users/objects/bob/events/2013Z": "/tables/:name/objects/:id/events/:timestamp",
+		"/tables/users/events":                   "/tables/:name/events",
+	}
+	for path, template := range tests {
+		assert.Equal(t, PathTemplate(path), template, path)
+	}
+}
+
+// Ensure that metrics are written in the text exposition format.
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Total requests.")
+	c.Add(Labels{"path": "/b"}, 1)
+	c.Add(Labels{"path": "/a", "status": `"x"`}, 2)
+	c.Add(Labels{"path": "/b"}, 1)
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	h.Observe(nil, 0.5)
+	h.Observe(nil, 2)
+	assert.Equal(t, r.Counter("requests_total", ""), c)
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, n, int64(buf.Len()))
+	assert.Equal(t, buf.String(), `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 0
+latency_seconds_bucket{le="1"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 2.5
+latency_seconds_count 2
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{path="/a",status="\"x\""} 2
+requests_total{path="/b"} 2
+`)
+}
+
+// Ensure that a name cannot be registered with two metric types.
+func TestRegistryTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests", "")
+	assert.Equal(t, r.Counter("requests", ""), c)
+	assert.Panics(t, func() { r.Histogram("requests", "", nil) })
+}
+
+// Ensure that an instrumented client records its requests.
+func TestInstrument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ping" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	Instrument(c, r)
+	c.Ping()
+	c.Table("users")
+
+	w := httptest.NewRecorder()
+	Handler(r).ServeHTTP(w, nil)
+	assert.Equal(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(t, w.Body.String(), `sky_requests_total{method="GET",path="/ping",status="2xx"} 1`)
+	assert.Contains(t, w.Body.String(), `sky_requests_total{method="GET",path="/tables/:name",status="4xx"} 1`)
+	assert.Contains(t, w.Body.String(), `sky_request_duration_seconds_count{method="GET",path="/ping"} 1`)
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets, in seconds, used for latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Labels are the dimensions of a single series of a metric.
+type Labels map[string]string
+
+// Counter is a metric that only increases.
+type Counter interface {
+	Add(labels Labels, v float64)
+}
+
+// Histogram is a metric that counts observations in buckets.
+type Histogram interface {
+	Observe(labels Labels, v float64)
+}
+
+// Registry creates metrics and writes them in the Prometheus text exposition
+// format. Requesting a metric that already exists returns the existing one.
+// Requesting a metric with the name of a metric of another type panics.
+type Registry interface {
+	Counter(name string, help string) Counter
+	Histogram(name string, help string, buckets []float64) Histogram
+	WriteTo(w io.Writer) (int64, error)
+}
+
+// Handler returns an HTTP handler that serves the metrics in a registry.
+func Handler(r Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WriteTo(w)
+	})
+}
+
+// NewRegistry returns an in-memory registry.
+func NewRegistry() Registry {
+	return &registry{metrics: make(map[string]*metric)}
+}
+
+type registry struct {
+	mutex   sync.Mutex
+	metrics map[string]*metric
+}
+
+// metric holds all series of a counter or histogram.
+type metric struct {
+	registry *registry
+	name     string
+	help     string
+	typ      string
+	buckets  []float64
+	series   map[string]*series
+}
+
+// series holds the values for a single set of labels.
+type series struct {
+	labels string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+func (r *registry) Counter(name string, help string) Counter {
+	return r.metric(name, help, "counter", nil)
+}
+
+func (r *registry) Histogram(name string, help string, buckets []float64) Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return r.metric(name, help, "histogram", buckets)
+}
+
+func (r *registry) metric(name string, help string, typ string, buckets []float64) *metric {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.typ != typ {
+			panic(fmt.Sprintf("metrics: %s already registered as a %s", name, m.typ))
+		}
+		return m
+	}
+	m := &metric{registry: r, name: name, help: help, typ: typ, buckets: buckets, series: make(map[string]*series)}
+	r.metrics[name] = m
+	return m
+}
+
+// get returns the series for a set of labels. The registry lock must be held.
+func (m *metric) get(labels Labels) *series {
+	key := formatLabels(labels)
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labels: key, counts: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) Add(labels Labels, v float64) {
+	m.registry.mutex.Lock()
+	defer m.registry.mutex.Unlock()
+	m.get(labels).value += v
+}
+
+func (m *metric) Observe(labels Labels, v float64) {
+	m.registry.mutex.Lock()
+	defer m.registry.mutex.Unlock()
+	s := m.get(labels)
+	s.value += v
+	s.count++
+	for i, upper := range m.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+}
+
+// WriteTo writes all metrics sorted by name and series sorted by labels.
+func (r *registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := r.metrics[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := m.series[key]
+			if m.typ == "counter" {
+				fmt.Fprintf(bw, "%s%s %s\n", m.name, braces(s.labels), formatFloat(s.value))
+				continue
+			}
+			for i, upper := range m.buckets {
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", m.name, braces(join(s.labels, `le="`+formatFloat(upper)+`"`)), s.counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", m.name, braces(join(s.labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", m.name, braces(s.labels), formatFloat(s.value))
+			fmt.Fprintf(bw, "%s_count%s %d\n", m.name, braces(s.labels), s.count)
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// formatLabels formats labels sorted by name, without braces.
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func join(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countWriter counts the bytes written to an underlying writer.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,142 @@
+package sky
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Doer sends an HTTP request and returns the response. *http.Client is a Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc is an adapter to allow a function to be used as a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer to add behavior around every request sent by a
+// client, such as logging, metrics, header rewriting or fault injection.
+type Middleware func(next Doer) Doer
+
+// StreamEventType is the type of change in a stream's lifecycle.
+type StreamEventType int
+
+const (
+	// StreamConnect occurs when a stream first connects to the server.
+	StreamConnect StreamEventType = iota
+
+	// StreamFlush occurs when buffered events are sent to the server.
+	StreamFlush
+
+	// StreamReconnect occurs when a stream replaces its connection.
+	StreamReconnect
+
+	// StreamClose occurs when a stream is closed.
+	StreamClose
+)
+
+// String returns the name of the event type.
+func (t StreamEventType) String() string {
+	switch t {
+	case StreamConnect:
+		return "connect"
+	case StreamFlush:
+		return "flush"
+	case StreamReconnect:
+		return "reconnect"
+	case StreamClose:
+		return "close"
+	}
+	return "unknown"
+}
+
+// StreamEvent describes a change in a stream's lifecycle.
+type StreamEvent struct {
+	Type StreamEventType
+
+	// Path is the request path of the stream, such as "/tables/foo/events".
+	Path string
+
+	// Events and Bytes are the number of events and bytes written to the
+	// stream since the previous flush. They are only set for StreamFlush.
+	Events int
+	Bytes  int64
+
+	// Duration is the time the operation took.
+	Duration time.Duration
+
+	// Err is the error from the operation, if any.
+	Err error
+}
+
+// StreamListener is notified of stream lifecycle events.
+type StreamListener func(StreamEvent)
+
+// Use adds middleware to the client. The first middleware added is the
+// outermost one and sees each request first.
+func (c *Client) Use(m ...Middleware) {
+	c.Middleware = append(c.Middleware, m...)
+}
+
+// Listen adds a listener for the lifecycle events of the client's streams.
+func (c *Client) Listen(l ...StreamListener) {
+	c.StreamListeners = append(c.StreamListeners, l...)
+}
+
+// doer returns the client's HTTP client wrapped in its middleware.
+func (c *Client) doer() Doer {
+	var d Doer = &c.HTTPClient
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		d = c.Middleware[i](d)
+	}
+	return d
+}
+
+// notify sends a stream event to all listeners.
+func (c *Client) notify(e StreamEvent) {
+	for _, l := range c.StreamListeners {
+		l(e)
+	}
+}
+
+// LoggingMiddleware logs the method, URL, status and duration of each request
+// at info level. Failed requests are logged at warn level.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return TimingMiddleware(func(req *http.Request, resp *http.Response, err error, d time.Duration) {
+		if err != nil {
+			logger.Warn("sky: request failed", "method", req.Method, "url", req.URL.String(), "duration", d, "error", err)
+			return
+		}
+		logger.Info("sky: request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", d)
+	})
+}
+
+// TimingMiddleware calls fn with the response and duration of each request.
+// The response is nil if err is not nil.
+func TimingMiddleware(fn func(req *http.Request, resp *http.Response, err error, d time.Duration)) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			t := time.Now()
+			resp, err := next.Do(req)
+			fn(req, resp, err, time.Since(t))
+			return resp, err
+		})
+	}
+}
+
+// LoggingStreamListener logs each stream lifecycle event at info level.
+// Failed operations are logged at warn level.
+func LoggingStreamListener(logger *slog.Logger) StreamListener {
+	return func(e StreamEvent) {
+		if e.Err != nil {
+			logger.Warn("sky: stream "+e.Type.String()+" failed", "path", e.Path, "duration", e.Duration, "error", e.Err)
+			return
+		}
+		logger.Info("sky: stream "+e.Type.String(), "path", e.Path, "duration", e.Duration)
+	}
+}
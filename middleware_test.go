@@ -0,0 +1,98 @@
+package sky
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that middleware wraps requests in the order it was added.
+func TestClientMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer server.Close()
+
+	header := func(value string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Trace", req.Header.Get("X-Trace")+value)
+				return next.Do(req)
+			})
+		}
+	}
+	var statuses []int
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	c.Use(header("a"), header("b"))
+	c.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			b, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, string(b), "ab")
+			statuses = append(statuses, resp.StatusCode)
+			return resp, err
+		})
+	})
+	assert.True(t, c.Ping())
+	assert.Equal(t, statuses, []int{http.StatusOK})
+}
+
+// Ensure that requests and stream events are logged through slog.
+func TestLoggingMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	c.Use(LoggingMiddleware(logger))
+	assert.True(t, c.Ping())
+	assert.Contains(t, buf.String(), `level=INFO msg="sky: request" method=GET`)
+	assert.Contains(t, buf.String(), "status=200")
+
+	buf.Reset()
+	LoggingStreamListener(logger)(StreamEvent{Type: StreamReconnect, Path: "/events", Err: io.EOF})
+	assert.Contains(t, buf.String(), `level=WARN msg="sky: stream reconnect failed" path=/events`)
+	assert.Contains(t, buf.String(), "error=EOF")
+}
+
+// Ensure that stream listeners are notified of lifecycle events.
+func TestClientStreamListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go ioutil.ReadAll(conn)
+		}
+	}()
+
+	var events []StreamEvent
+	c := &Client{Host: ln.Addr().String()}
+	c.Listen(func(e StreamEvent) { events = append(events, e) })
+	stream, err := (&Table{Client: c, Name: "t0"}).Stream()
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Reconnect())
+	assert.NoError(t, stream.Close())
+
+	types := []StreamEventType{}
+	for _, e := range events {
+		assert.Equal(t, e.Path, "/tables/t0/events")
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, types, []StreamEventType{StreamConnect, StreamReconnect, StreamFlush, StreamClose})
+}
@@ -0,0 +1,126 @@
+package sky
+
+import (
+	"context"
+	"fmt"
+)
+
+// PropertyConverter converts a property value during a migration.
+type PropertyConverter func(v interface{}) (interface{}, error)
+
+const (
+	migrateSuffix = "__migrate"
+	backupSuffix  = "__backup"
+)
+
+// MigrateProperty changes the definition of a property and rewrites its
+// existing values. The converted values are written to a temporary property
+// before it replaces the original. If newDef has a blank name then the
+// property keeps its name. A nil converter copies values unchanged.
+//
+// If the rewrite fails then the rewritten events are restored, the temporary
+// property is deleted and the original property is left as it was. Once the
+// new property has replaced the original, a failure to delete the backup of
+// the original is logged rather than returned since the migration is done.
+//
+// Writes to the property are not blocked during the migration. Values written
+// to an object after it has been rewritten are not copied and are lost when
+// the original property is deleted, so writers should be stopped first.
+func (t *Table) MigrateProperty(name string, newDef *Property, converter PropertyConverter) error {
+	if t.Client == nil {
+		return ErrClientRequired
+	} else if name == "" {
+		return ErrPropertyNameRequired
+	} else if newDef == nil {
+		return ErrPropertyRequired
+	}
+	if _, err := t.Property(name); err != nil {
+		return err
+	}
+	if converter == nil {
+		converter = func(v interface{}) (interface{}, error) { return v, nil }
+	}
+	newName := newDef.Name
+	if newName == "" {
+		newName = name
+	}
+
+	// Write the converted values to a temporary property.
+	tmp := &Property{Name: name + migrateSuffix, Transient: newDef.Transient, DataType: newDef.DataType}
+	if err := t.CreateProperty(tmp); err != nil {
+		return err
+	}
+	rewritten, err := t.rewriteProperty(name, tmp.Name, converter)
+	if err != nil {
+		t.restore(rewritten)
+		t.rollback(func() error { return t.DeleteProperty(tmp.Name) })
+		return err
+	}
+
+	// Swap the temporary property in for the original. The original is kept
+	// under a backup name until the swap succeeds.
+	backup := name + backupSuffix
+	if err := t.RenameProperty(name, backup); err != nil {
+		t.restore(rewritten)
+		t.rollback(func() error { return t.DeleteProperty(tmp.Name) })
+		return err
+	}
+	if err := t.RenameProperty(tmp.Name, newName); err != nil {
+		t.rollback(func() error { return t.RenameProperty(backup, name) })
+		t.restore(rewritten)
+		t.rollback(func() error { return t.DeleteProperty(tmp.Name) })
+		return err
+	}
+	if err := t.DeleteProperty(backup); err != nil {
+		t.Client.logger().Warn("sky: migration backup delete failed", "table", t.Name, "property", backup, "error", err)
+	}
+	return nil
+}
+
+// rewriteProperty copies the converted value of a property on every event to
+// another property. The original events that were rewritten are returned so
+// that they can be restored, including when an error is returned.
+func (t *Table) rewriteProperty(from, to string, converter PropertyConverter) ([]ObjectEvent, error) {
+	keys, err := t.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var rewritten []ObjectEvent
+	for _, id := range keys {
+		events, err := t.Events(id)
+		if err != nil {
+			return rewritten, err
+		}
+		for _, e := range events {
+			v, ok := e.Data[from]
+			if !ok {
+				continue
+			}
+			if v, err = converter(v); err != nil {
+				return rewritten, err
+			}
+			rewritten = append(rewritten, ObjectEvent{ID: id, Event: e})
+			if err := t.insertEvent(context.Background(), id, &Event{Timestamp: e.Timestamp, Data: map[string]interface{}{to: v}}, false); err != nil {
+				return rewritten, err
+			}
+		}
+	}
+	return rewritten, nil
+}
+
+// restore replaces rewritten events with their original data so that no
+// converted values are left on them.
+func (t *Table) restore(events []ObjectEvent) {
+	for _, item := range events {
+		t.rollback(func() error {
+			return t.Client.Send("PUT", fmt.Sprintf("/tables/%s/objects/%s/events/%s", t.Name, item.ID, FormatTimestamp(item.Event.Timestamp)), item.Event.Serialize(), nil)
+		})
+	}
+}
+
+// rollback runs a step of a failed migration and logs its error.
+func (t *Table) rollback(fn func() error) {
+	if err := fn(); err != nil {
+		t.Client.logger().Warn("sky: migration rollback failed", "table", t.Name, "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package sky
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that a property can change type and name with its values converted.
+func TestTableMigrateProperty(t *testing.T) {
+	s, c := newFakeServer(t)
+	defer s.Close()
+	ft := s.table("t0", &Property{Name: "count", DataType: String}, &Property{Name: "action", Transient: true, DataType: Factor})
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	ft.insert("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "3", "action": "a"}})
+	ft.insert("o0", &Event{Timestamp: t0.Add(time.Second), Data: map[string]interface{}{"action": "b"}})
+	ft.insert("o1", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "12"}})
+
+	table := &Table{Client: c, Name: "t0"}
+	err := table.MigrateProperty("count", &Property{Name: "total", DataType: Integer}, func(v interface{}) (interface{}, error) {
+		return strconv.Atoi(v.(string))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ft.properties, []*Property{{Name: "action", Transient: true, DataType: Factor}, {Name: "total", DataType: Integer}})
+	assert.Equal(t, ft.objects["o0"][FormatTimestamp(t0)], map[string]interface{}{"total": float64(3), "action": "a"})
+	assert.Equal(t, ft.objects["o0"][FormatTimestamp(t0.Add(time.Second))], map[string]interface{}{"action": "b"})
+	assert.Equal(t, ft.objects["o1"][FormatTimestamp(t0)], map[string]interface{}{"total": float64(12)})
+}
+
+// Ensure that a failed migration leaves the original property unchanged.
+func TestTableMigratePropertyRollback(t *testing.T) {
+	s, c := newFakeServer(t)
+	defer s.Close()
+	ft := s.table("t0", &Property{Name: "count", DataType: String})
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	ft.insert("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "3"}})
+	ft.insert("o1", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "x"}})
+
+	table := &Table{Client: c, Name: "t0"}
+	errInvalid := errors.New("invalid")
+	err := table.MigrateProperty("count", &Property{DataType: Integer}, func(v interface{}) (interface{}, error) {
+		if v == "x" {
+			return nil, errInvalid
+		}
+		return v, nil
+	})
+	assert.Equal(t, err, errInvalid)
+	assert.Equal(t, ft.properties, []*Property{{Name: "count", DataType: String}})
+	assert.Equal(t, ft.objects["o0"][FormatTimestamp(t0)], map[string]interface{}{"count": "3"})
+
+	assert.IsType(t, &APIError{}, table.MigrateProperty("missing", &Property{DataType: Integer}, nil))
+}
+
+// failDeletes makes the client fail requests to delete properties.
+func failDeletes(c *Client) {
+	c.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "DELETE" && strings.Contains(req.URL.Path, "/properties/") {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"message":"unavailable"}`)), Request: req}, nil
+			}
+			return next.Do(req)
+		})
+	})
+}
+
+// Ensure that rewritten events are restored by a rollback even if the
+// temporary property cannot be deleted.
+func TestTableMigratePropertyRestore(t *testing.T) {
+	s, c := newFakeServer(t)
+	defer s.Close()
+	ft := s.table("t0", &Property{Name: "count", DataType: String})
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	ft.insert("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "3"}})
+	ft.insert("o1", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "x"}})
+	failDeletes(c)
+
+	table := &Table{Client: c, Name: "t0"}
+	err := table.MigrateProperty("count", &Property{DataType: Integer}, func(v interface{}) (interface{}, error) {
+		if v == "x" {
+			return nil, errors.New("invalid")
+		}
+		return strconv.Atoi(v.(string))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, len(ft.properties), 2)
+	assert.Equal(t, ft.objects["o0"][FormatTimestamp(t0)], map[string]interface{}{"count": "3"})
+	assert.Equal(t, ft.objects["o1"][FormatTimestamp(t0)], map[string]interface{}{"count": "x"})
+}
+
+// Ensure that a migration succeeds and logs a warning if the backup of the
+// original property cannot be deleted.
+func TestTableMigratePropertyBackup(t *testing.T) {
+	s, c := newFakeServer(t)
+	defer s.Close()
+	ft := s.table("t0", &Property{Name: "count", DataType: String})
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	ft.insert("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"count": "3"}})
+	failDeletes(c)
+	var buf bytes.Buffer
+	c.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	table := &Table{Client: c, Name: "t0"}
+	assert.NoError(t, table.MigrateProperty("count", &Property{DataType: Integer}, nil))
+	assert.Equal(t, ft.properties, []*Property{{Name: "count__backup", DataType: String}, {Name: "count", DataType: Integer}})
+	assert.Contains(t, buf.String(), `level=WARN msg="sky: migration backup delete failed" table=t0 property=count__backup`)
+}
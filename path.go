@@ -0,0 +1,128 @@
+package sky
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ActionPath is a sequence of property values that followed an action and
+// the number of times it occurred.
+type ActionPath struct {
+	Values []string `json:"values"`
+	Count  int      `json:"count"`
+}
+
+// NextActions returns the values of a property on the events that followed
+// each event where the property equals value. Paths are up to depth events
+// long and are shorter when an object has no more events. Events without the
+// property have a blank value in the path. The most common
+// paths are returned first. If limit is positive then only that many paths
+// are returned.
+func (t *Table) NextActions(property string, value interface{}, depth, limit int) ([]*ActionPath, error) {
+	q, err := NextActionsQuery(property, value, depth)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.Query(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths are grouped by their length first so that a missing value on
+	// an event is not mistaken for the end of the path.
+	paths := []*ActionPath{}
+	var walk func(m map[string]interface{}, values []string, length int)
+	walk = func(m map[string]interface{}, values []string, length int) {
+		if len(values) == depth {
+			count, _ := m["count"].(float64)
+			paths = append(paths, &ActionPath{Values: values[:length], Count: int(count)})
+			return
+		}
+		children, _ := m[nextActionVar(len(values))].(map[string]interface{})
+		for key, child := range children {
+			child, _ := child.(map[string]interface{})
+			walk(child, append(values[:len(values):len(values)], key), length)
+		}
+	}
+	lengths, _ := output[nextActionLength].(map[string]interface{})
+	for key, m := range lengths {
+		length, err := strconv.Atoi(key)
+		if err != nil || length < 1 || length > depth {
+			continue
+		}
+		m, _ := m.(map[string]interface{})
+		walk(m, []string{}, length)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count != paths[j].Count {
+			return paths[i].Count > paths[j].Count
+		}
+		return strings.Join(paths[i].Values, "\x00") < strings.Join(paths[j].Values, "\x00")
+	})
+	if limit > 0 && len(paths) > limit {
+		paths = paths[:limit]
+	}
+	return paths, nil
+}
+
+// NextActionsQuery generates the SkyQL used by Table.NextActions. Each step
+// of the path is stored in a factor variable and the path is selected when it
+// reaches its full depth or the object's last event. The number of steps
+// taken is stored in the next_action_length variable and selected as the
+// first dimension so that the end of a path is explicit.
+func NextActionsQuery(property string, value interface{}, depth int) (string, error) {
+	if property == "" {
+		return "", ErrPropertyNameRequired
+	} else if !isIdent(property) {
+		return "", ErrInvalidPropertyName
+	} else if depth < 1 {
+		return "", ErrPathDepthRequired
+	}
+	literal, err := queryLiteral(value)
+	if err != nil {
+		return "", err
+	}
+
+	vars := make([]string, depth)
+	for i := range vars {
+		vars[i] = nextActionVar(i)
+	}
+	selection := "SELECT count() GROUP BY " + nextActionLength + ", " + strings.Join(vars, ", ")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DECLARE %s AS INTEGER\n", nextActionLength)
+	for _, v := range vars {
+		fmt.Fprintf(&buf, "DECLARE %s AS FACTOR(%s)\n", v, property)
+	}
+	fmt.Fprintf(&buf, "WHEN %s == %s THEN\n", property, literal)
+	fmt.Fprintf(&buf, "  SET %s = 0\n", nextActionLength)
+	for _, v := range vars {
+		fmt.Fprintf(&buf, "  SET %s = \"\"\n", v)
+	}
+	for i, v := range vars {
+		indent := strings.Repeat("  ", i+1)
+		fmt.Fprintf(&buf, "%sWHEN true WITHIN 1 .. 1 STEPS THEN\n", indent)
+		fmt.Fprintf(&buf, "%s  SET %s = %d\n", indent, nextActionLength, i+1)
+		fmt.Fprintf(&buf, "%s  SET %s = %s\n", indent, v, property)
+		if i < depth-1 {
+			fmt.Fprintf(&buf, "%s  WHEN @eof THEN\n%s    %s\n%s  END\n", indent, indent, selection, indent)
+		} else {
+			fmt.Fprintf(&buf, "%s  %s\n", indent, selection)
+		}
+	}
+	for i := depth; i >= 0; i-- {
+		fmt.Fprintf(&buf, "%sEND\n", strings.Repeat("  ", i))
+	}
+	return buf.String(), nil
+}
+
+// nextActionLength is the variable holding the number of steps in a path.
+const nextActionLength = "next_action_length"
+
+func nextActionVar(i int) string {
+	return fmt.Sprintf("next_action_%d", i+1)
+}
@@ -0,0 +1,103 @@
+package sky_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that the paths following an action are counted.
+func TestTableNextActions(t *testing.T) {
+	server, table := newQueryServer(t, analyticsProperties, analyticsEvents())
+	defer server.Close()
+
+	paths, err := table.NextActions("action", "home", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []*sky.ActionPath{{Values: []string{"cart", "checkout"}, Count: 2}})
+
+	// Paths that reach the last event are shortened.
+	paths, err = table.NextActions("action", "cart", 3, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []*sky.ActionPath{{Values: []string{"checkout"}, Count: 2}})
+
+	paths, err = table.NextActions("action", "checkout", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, len(paths), 0)
+
+	_, err = table.NextActions("action", "home", 0, 0)
+	assert.Equal(t, err, sky.ErrPathDepthRequired)
+	_, err = table.NextActions("action)", "home", 1, 0)
+	assert.Equal(t, err, sky.ErrInvalidPropertyName)
+}
+
+// Ensure that only the most common paths are returned when limited.
+func TestTableNextActionsLimit(t *testing.T) {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	events := append(analyticsEvents(),
+		sky.ObjectEvent{ID: "o3", Event: &sky.Event{Timestamp: t0, Data: map[string]interface{}{"action": "home"}}},
+		sky.ObjectEvent{ID: "o3", Event: &sky.Event{Timestamp: t0.Add(time.Minute), Data: map[string]interface{}{"action": "checkout"}}},
+	)
+	server, table := newQueryServer(t, analyticsProperties, events)
+	defer server.Close()
+
+	paths, err := table.NextActions("action", "home", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []*sky.ActionPath{{Values: []string{"cart"}, Count: 2}, {Values: []string{"checkout"}, Count: 1}})
+
+	paths, err = table.NextActions("action", "home", 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []*sky.ActionPath{{Values: []string{"cart"}, Count: 2}})
+}
+
+// Ensure that a missing value is kept in the path rather than ending it.
+func TestTableNextActionsMissingValue(t *testing.T) {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	event := func(d time.Duration, data map[string]interface{}) sky.ObjectEvent {
+		return sky.ObjectEvent{ID: "o0", Event: &sky.Event{Timestamp: t0.Add(d), Data: data}}
+	}
+	server, table := newQueryServer(t, analyticsProperties, []sky.ObjectEvent{
+		event(0, map[string]interface{}{"action": "home"}),
+		event(time.Minute, map[string]interface{}{"gender": "f"}),
+		event(2*time.Minute, map[string]interface{}{"action": "cart"}),
+		event(3*time.Minute, map[string]interface{}{"action": "home"}),
+		event(4*time.Minute, map[string]interface{}{"gender": "m"}),
+	})
+	defer server.Close()
+
+	paths, err := table.NextActions("action", "home", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, []*sky.ActionPath{
+		{Values: []string{""}, Count: 1},
+		{Values: []string{"", "cart"}, Count: 1},
+	})
+}
+
+// Ensure that path queries store each following value and the path length in
+// variables.
+func TestNextActionsQuery(t *testing.T) {
+	q, err := sky.NextActionsQuery("action", "home", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, q, ""+
+		"DECLARE next_action_length AS INTEGER\n"+
+		"DECLARE next_action_1 AS FACTOR(action)\n"+
+		"DECLARE next_action_2 AS FACTOR(action)\n"+
+		"WHEN action == \"home\" THEN\n"+
+		"  SET next_action_length = 0\n"+
+		"  SET next_action_1 = \"\"\n"+
+		"  SET next_action_2 = \"\"\n"+
+		"  WHEN true WITHIN 1 .. 1 STEPS THEN\n"+
+		"    SET next_action_length = 1\n"+
+		"    SET next_action_1 = action\n"+
+		"    WHEN @eof THEN\n"+
+		"      SELECT count() GROUP BY next_action_length, next_action_1, next_action_2\n"+
+		"    END\n"+
+		"    WHEN true WITHIN 1 .. 1 STEPS THEN\n"+
+		"      SET next_action_length = 2\n"+
+		"      SET next_action_2 = action\n"+
+		"      SELECT count() GROUP BY next_action_length, next_action_1, next_action_2\n"+
+		"    END\n"+
+		"  END\n"+
+		"END\n")
+}
@@ -0,0 +1,166 @@
+package sky
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultProducerBufferSize is the number of events a producer queues
+	// if no buffer size is given.
+	DefaultProducerBufferSize = 10000
+
+	// ProducerFlushInterval is how often a producer flushes its stream.
+	ProducerFlushInterval = time.Second
+)
+
+// Producer sends events to a table in the background through a stream so
+// that callers never block on the server. Events are queued and dropped when
+// the queue is full. A producer is safe for concurrent use.
+type Producer struct {
+	table   *Table
+	queue   chan ObjectEvent
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	dropped uint64
+	err     error
+}
+
+// NewProducer starts a producer for a table that queues up to bufferSize
+// events. If bufferSize is zero then DefaultProducerBufferSize is used.
+func NewProducer(t *Table, bufferSize int) *Producer {
+	if bufferSize <= 0 {
+		bufferSize = DefaultProducerBufferSize
+	}
+	p := &Producer{
+		table: t,
+		queue: make(chan ObjectEvent, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Send queues an event for an object. Returns false if the event was dropped
+// because the queue is full or the producer is closed.
+func (p *Producer) Send(id string, e *Event) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if !p.closed {
+		select {
+		case p.queue <- ObjectEvent{ID: id, Event: e}:
+			return true
+		default:
+		}
+	}
+	atomic.AddUint64(&p.dropped, 1)
+	return false
+}
+
+// Table returns the table that events are sent to.
+func (p *Producer) Table() *Table {
+	return p.table
+}
+
+// Dropped returns the number of events that were dropped because the queue
+// was full, the producer was closed or the server could not be reached.
+// Events buffered on a connection that fails are counted as dropped even if
+// some of them reached the server.
+func (p *Producer) Dropped() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+// Close sends the queued events and closes the stream. Returns the error
+// from closing the stream, if any.
+func (p *Producer) Close() error {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mutex.Unlock()
+	<-p.done
+	return p.err
+}
+
+// run sends queued events until the producer is closed.
+func (p *Producer) run() {
+	defer close(p.done)
+	var stream *TableEventStream
+	ticker := time.NewTicker(ProducerFlushInterval)
+	defer ticker.Stop()
+
+	// A stream that cannot reconnect is discarded and a new one is opened
+	// for the next event.
+	reconnect := func() error {
+		err := stream.Reconnect()
+		if err != nil {
+			stream = nil
+		}
+		return err
+	}
+	send := func(item ObjectEvent) {
+		if stream == nil {
+			s, err := p.table.Stream()
+			if err != nil {
+				p.drop(item, err)
+				return
+			}
+			stream = s
+		}
+		if err := stream.InsertEvent(item.ID, item.Event); err != nil {
+			// Retry once on a new connection. Events buffered on the old
+			// connection are lost with it.
+			p.lose(stream.events, err)
+			if err = reconnect(); err == nil {
+				err = stream.InsertEvent(item.ID, item.Event)
+			}
+			if err != nil {
+				p.drop(item, err)
+			}
+		}
+	}
+
+	for {
+		select {
+		case item, ok := <-p.queue:
+			if !ok {
+				if stream != nil {
+					n := stream.events
+					if p.err = stream.Close(); p.err != nil {
+						p.lose(n, p.err)
+					}
+				}
+				return
+			}
+			send(item)
+		case <-ticker.C:
+			if stream == nil {
+				continue
+			}
+			n := stream.events
+			if err := stream.Flush(); err != nil {
+				p.lose(n, err)
+				reconnect()
+			}
+		}
+	}
+}
+
+// drop counts and logs an event that could not be sent.
+func (p *Producer) drop(item ObjectEvent, err error) {
+	atomic.AddUint64(&p.dropped, 1)
+	p.table.Client.logger().Warn("sky: dropped event", "table", p.table.Name, "id", item.ID, "error", err)
+}
+
+// lose counts and logs the events buffered on a stream whose connection
+// failed. Some of them may have reached the server before the failure.
+func (p *Producer) lose(n int, err error) {
+	if n == 0 {
+		return
+	}
+	atomic.AddUint64(&p.dropped, uint64(n))
+	p.table.Client.logger().Warn("sky: dropped events", "table", p.table.Name, "count", n, "error", err)
+}
@@ -0,0 +1,123 @@
+package sky
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that queued events are sent when a producer is closed.
+func TestProducerSend(t *testing.T) {
+	s, c := newFakeServer(t)
+	defer s.Close()
+	table := s.table("t0", &Property{Name: "action", DataType: Factor})
+
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	p := NewProducer(&Table{Client: c, Name: "t0"}, 0)
+	assert.True(t, p.Send("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"action": "signup"}}))
+	assert.True(t, p.Send("o0", &Event{Timestamp: t0.Add(time.Second), Data: map[string]interface{}{"action": "login"}}))
+	assert.NoError(t, p.Close())
+	assert.Equal(t, p.Dropped(), uint64(0))
+	s.waitStreams(1)
+	assert.Equal(t, table.events("o0"), []map[string]interface{}{
+		{"timestamp": "1970-01-01T00:00:00Z", "data": map[string]interface{}{"action": "signup"}},
+		{"timestamp": "1970-01-01T00:00:01Z", "data": map[string]interface{}{"action": "login"}},
+	})
+
+	// Events sent after close are dropped.
+	assert.False(t, p.Send("o0", &Event{Timestamp: t0, Data: map[string]interface{}{}}))
+	assert.Equal(t, p.Dropped(), uint64(1))
+	assert.NoError(t, p.Close())
+}
+
+// Ensure that events are dropped when the server cannot be reached.
+func TestProducerUnreachable(t *testing.T) {
+	s, c := newFakeServer(t)
+	s.Close()
+
+	p := NewProducer(&Table{Client: c, Name: "t0"}, 1)
+	for i := 0; i < 3; i++ {
+		p.Send("o0", &Event{Timestamp: time.Now(), Data: map[string]interface{}{}})
+	}
+	assert.NoError(t, p.Close())
+	assert.Equal(t, p.Dropped(), uint64(3))
+}
+
+// Ensure that events are only sent through the stream as chunked NDJSON.
+func TestProducerStreamBody(t *testing.T) {
+	var requests []string
+	body := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Proto)
+		if conn, rw, err := w.(http.Hijacker).Hijack(); err == nil {
+			b, _ := io.ReadAll(rw)
+			conn.Close()
+			body <- b
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	p := NewProducer(&Table{Client: c, Name: "t0"}, 0)
+	p.Send("o0", &Event{Timestamp: t0, Data: map[string]interface{}{"action": "signup"}})
+	p.Send("o1", &Event{Timestamp: t0, Data: map[string]interface{}{"action": "login"}})
+	assert.NoError(t, p.Close())
+
+	b := <-body
+	assert.Equal(t, requests, []string{"PATCH /tables/t0/events HTTP/1.0"})
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(httputil.NewChunkedReader(strings.NewReader(string(b))))
+	for scanner.Scan() {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &m))
+		lines = append(lines, m)
+	}
+	assert.Equal(t, lines, []map[string]interface{}{
+		{"id": "o0", "timestamp": "1970-01-01T00:00:00Z", "data": map[string]interface{}{"action": "signup"}},
+		{"id": "o1", "timestamp": "1970-01-01T00:00:00Z", "data": map[string]interface{}{"action": "login"}},
+	})
+}
+
+// Ensure that events buffered on a failed connection are counted as dropped.
+func TestProducerFlushError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+
+	// Reset the connection once the stream's header is read.
+	reset := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(conn)
+		for {
+			if line, err := r.ReadString('\n'); err != nil || line == "\r\n" {
+				break
+			}
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+		close(reset)
+	}()
+
+	c := &Client{Host: ln.Addr().String()}
+	p := NewProducer(&Table{Client: c, Name: "t0"}, 0)
+	p.Send("o0", &Event{Timestamp: time.Now(), Data: map[string]interface{}{}})
+	<-reset
+	assert.Error(t, p.Close())
+	assert.Equal(t, p.Dropped(), uint64(1))
+}
@@ -0,0 +1,142 @@
+package sky
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RetentionMatrix is the number of objects in each cohort that returned in
+// each period after the cohort started.
+type RetentionMatrix struct {
+	Bucket  time.Duration      `json:"bucket"`
+	Periods int                `json:"periods"`
+	Cohorts []*RetentionCohort `json:"cohorts"`
+}
+
+// RetentionCohort is the set of objects first seen in the same bucket.
+type RetentionCohort struct {
+	Start time.Time `json:"start"`
+	Size  int       `json:"size"`
+
+	// Retained is the number of objects that returned in each period. The
+	// first period is the cohort's own bucket.
+	Retained []int `json:"retained"`
+
+	// Rates is the fraction of the cohort that returned in each period.
+	Rates []float64 `json:"rates"`
+}
+
+// Retention groups objects into cohorts by the bucket that they first match
+// cohortEvent in and counts the objects in each cohort that match returnEvent
+// in each of the following periods. Both events are SkyQL expressions such as
+// `action == "signup"`. Buckets are aligned to the Unix epoch.
+func (t *Table) Retention(cohortEvent, returnEvent string, bucket time.Duration, periods int) (*RetentionMatrix, error) {
+	q, err := RetentionQuery(cohortEvent, returnEvent, bucket, periods)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return newRetentionMatrix(output, bucket, periods), nil
+}
+
+// RetentionQuery generates the SkyQL used by Table.Retention.
+func RetentionQuery(cohortEvent, returnEvent string, bucket time.Duration, periods int) (string, error) {
+	if bucket < time.Second {
+		return "", ErrRetentionBucketRequired
+	} else if cohortEvent == "" || returnEvent == "" {
+		return "", ErrQueryRequired
+	}
+	// Division truncates toward zero so the bucket is computed from the
+	// timestamp less its non-negative remainder to floor earlier timestamps.
+	secs := int64(bucket / time.Second)
+	bucketExpr := fmt.Sprintf("(@timestamp - (@timestamp %% %d + %d) %% %d) / %d", secs, secs, secs, secs)
+	return fmt.Sprintf(`DECLARE retention_seen AS BOOLEAN
+DECLARE retention_cohort AS INTEGER
+DECLARE retention_period AS INTEGER
+DECLARE retention_last AS INTEGER
+WHEN !retention_seen && (%s) THEN
+  SET retention_seen = true
+  SET retention_cohort = %s
+  SET retention_last = -1
+  SELECT count() AS size GROUP BY retention_cohort
+END
+WHEN retention_seen && (%s) THEN
+  SET retention_period = %s - retention_cohort
+  WHEN retention_period > retention_last && retention_period <= %d THEN
+    SET retention_last = retention_period
+    SELECT count() AS retained GROUP BY retention_cohort, retention_period
+  END
+END
+`, cohortEvent, bucketExpr, returnEvent, bucketExpr, periods), nil
+}
+
+// newRetentionMatrix reads the cohorts from a query result.
+func newRetentionMatrix(output map[string]interface{}, bucket time.Duration, periods int) *RetentionMatrix {
+	m := &RetentionMatrix{Bucket: bucket, Periods: periods, Cohorts: []*RetentionCohort{}}
+	cohorts, _ := output["retention_cohort"].(map[string]interface{})
+	for key, v := range cohorts {
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		result, _ := v.(map[string]interface{})
+		size, _ := result["size"].(float64)
+		c := &RetentionCohort{
+			Start:    time.Unix(0, 0).UTC().Add(time.Duration(n) * bucket),
+			Size:     int(size),
+			Retained: make([]int, periods+1),
+			Rates:    make([]float64, periods+1),
+		}
+		retained, _ := result["retention_period"].(map[string]interface{})
+		for key, v := range retained {
+			period, err := strconv.Atoi(key)
+			if err != nil || period < 0 || period > periods {
+				continue
+			}
+			count, _ := v.(map[string]interface{})["retained"].(float64)
+			c.Retained[period] = int(count)
+			c.Rates[period] = ratio(int(count), c.Size)
+		}
+		m.Cohorts = append(m.Cohorts, c)
+	}
+	sort.Slice(m.Cohorts, func(i, j int) bool { return m.Cohorts[i].Start.Before(m.Cohorts[j].Start) })
+	return m
+}
+
+// WriteJSON writes the matrix as JSON with each cohort's start time, size,
+// the number retained in each period and the retention rates.
+func (m *RetentionMatrix) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// WriteCSV writes the matrix as CSV with one row per cohort. The columns are
+// the cohort's start time, its size and the number retained in each period.
+func (m *RetentionMatrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"cohort", "size"}
+	for i := 0; i <= m.Periods; i++ {
+		header = append(header, strconv.Itoa(i))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, c := range m.Cohorts {
+		row := []string{FormatTimestamp(c.Start), strconv.Itoa(c.Size)}
+		for _, n := range c.Retained {
+			row = append(row, strconv.Itoa(n))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
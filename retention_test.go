@@ -0,0 +1,86 @@
+package sky_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that objects are grouped into cohorts and counted once per period.
+func TestTableRetention(t *testing.T) {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	day := 24 * time.Hour
+	event := func(id string, d time.Duration, action string) sky.ObjectEvent {
+		return sky.ObjectEvent{ID: id, Event: &sky.Event{Timestamp: t0.Add(d), Data: map[string]interface{}{"action": action}}}
+	}
+	server, table := newQueryServer(t, analyticsProperties, []sky.ObjectEvent{
+		event("o0", time.Hour, "signup"),
+		event("o0", 2*time.Hour, "visit"),
+		event("o0", day+time.Hour, "visit"),
+		event("o0", day+2*time.Hour, "visit"),
+		event("o0", 3*day, "visit"),
+		event("o1", day, "signup"),
+		event("o1", 2*day, "visit"),
+		event("o2", 0, "visit"),
+	})
+	defer server.Close()
+
+	m, err := table.Retention(`action == "signup"`, `action == "visit"`, day, 2)
+	assert.NoError(t, err)
+	if assert.Equal(t, len(m.Cohorts), 2) {
+		assert.Equal(t, m.Cohorts[0].Start, t0)
+		assert.Equal(t, m.Cohorts[0].Size, 1)
+		assert.Equal(t, m.Cohorts[0].Retained, []int{1, 1, 0})
+		assert.Equal(t, m.Cohorts[0].Rates, []float64{1, 1, 0})
+		assert.Equal(t, m.Cohorts[1].Start, t0.Add(day))
+		assert.Equal(t, m.Cohorts[1].Retained, []int{0, 1, 0})
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, m.WriteCSV(&buf))
+	assert.Equal(t, buf.String(), ""+
+		"cohort,size,0,1,2\n"+
+		"1970-01-01T00:00:00Z,1,1,1,0\n"+
+		"1970-01-02T00:00:00Z,1,0,1,0\n")
+
+	buf.Reset()
+	assert.NoError(t, m.WriteJSON(&buf))
+	var other sky.RetentionMatrix
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &other))
+	assert.Equal(t, &other, m)
+}
+
+// Ensure that objects before the Unix epoch are put in earlier cohorts and
+// periods rather than rounded toward zero.
+func TestTableRetentionBeforeEpoch(t *testing.T) {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	day := 24 * time.Hour
+	event := func(d time.Duration, action string) sky.ObjectEvent {
+		return sky.ObjectEvent{ID: "o0", Event: &sky.Event{Timestamp: t0.Add(d), Data: map[string]interface{}{"action": action}}}
+	}
+	server, table := newQueryServer(t, analyticsProperties, []sky.ObjectEvent{
+		event(-day-time.Hour, "signup"),
+		event(-time.Hour, "visit"),
+		event(time.Hour, "visit"),
+	})
+	defer server.Close()
+
+	m, err := table.Retention(`action == "signup"`, `action == "visit"`, day, 2)
+	assert.NoError(t, err)
+	if assert.Equal(t, len(m.Cohorts), 1) {
+		assert.Equal(t, m.Cohorts[0].Start, t0.Add(-2*day))
+		assert.Equal(t, m.Cohorts[0].Retained, []int{0, 1, 1})
+	}
+}
+
+// Ensure that invalid retention arguments are rejected.
+func TestRetentionQueryInvalid(t *testing.T) {
+	_, err := sky.RetentionQuery(`action == "a"`, `action == "b"`, 0, 2)
+	assert.Equal(t, err, sky.ErrRetentionBucketRequired)
+	_, err = sky.RetentionQuery("", `action == "b"`, time.Hour, 2)
+	assert.Equal(t, err, sky.ErrQueryRequired)
+}
@@ -0,0 +1,199 @@
+package sky
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSchemaTTL is how long a table's properties are cached if the client
+// does not set a SchemaTTL.
+const DefaultSchemaTTL = time.Minute
+
+// schema is the cached property list and factor values of a table.
+type schema struct {
+	mutex      sync.Mutex
+	properties []*Property
+	expires    time.Time
+
+	factors        map[string][]string
+	factorsExpires time.Time
+}
+
+// Schema returns the table's properties from the client's schema cache. The
+// properties are retrieved on first use and again after the cache expires or
+// is invalidated. Property changes made through the client invalidate the
+// cache, as do client errors from queries and inserts on the table.
+func (t *Table) Schema() ([]*Property, error) {
+	if t.Client == nil {
+		return nil, ErrClientRequired
+	}
+	ttl := t.Client.schemaTTL()
+	if ttl < 0 {
+		return t.Properties()
+	}
+
+	// Hold the entry's lock while loading so that concurrent callers share
+	// a single request.
+	s := t.schemaEntry()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(t, ttl); err != nil {
+		return nil, err
+	}
+	return copyProperties(s.properties), nil
+}
+
+// FactorValues returns the values that have been set on each of the table's
+// factor properties, in sorted order. The values are read with a single query
+// and cached with the table's properties in the client's schema cache.
+// Factors whose names cannot be used in a query are left out.
+func (t *Table) FactorValues() (map[string][]string, error) {
+	if t.Client == nil {
+		return nil, ErrClientRequired
+	}
+	ttl := t.Client.schemaTTL()
+	if ttl < 0 {
+		properties, err := t.Properties()
+		if err != nil {
+			return nil, err
+		}
+		return t.queryFactorValues(properties)
+	}
+
+	s := t.schemaEntry()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(t, ttl); err != nil {
+		return nil, err
+	}
+	if s.factors == nil || !time.Now().Before(s.factorsExpires) {
+		factors, err := t.queryFactorValues(s.properties)
+		if err != nil {
+			return nil, err
+		}
+		s.factors, s.factorsExpires = factors, time.Now().Add(ttl)
+	}
+	return copyFactorValues(s.factors), nil
+}
+
+// queryFactorValues reads the values of each factor property. The query is
+// sent without the client's query validator since the validator may need the
+// factor values itself.
+func (t *Table) queryFactorValues(properties []*Property) (map[string][]string, error) {
+	var buf bytes.Buffer
+	for _, p := range properties {
+		if p.DataType == Factor && isIdent(p.Name) {
+			fmt.Fprintf(&buf, "SELECT count() GROUP BY %s INTO %q\n", p.Name, p.Name)
+		}
+	}
+	factors := map[string][]string{}
+	if buf.Len() == 0 {
+		return factors, nil
+	}
+
+	output := map[string]interface{}{}
+	if err := t.Client.Send("POST", fmt.Sprintf("/tables/%s/query", t.Name), buf.String(), &output); err != nil {
+		t.refreshSchemaOn(err)
+		return nil, err
+	}
+	for _, p := range properties {
+		if p.DataType != Factor || !isIdent(p.Name) {
+			continue
+		}
+		into, _ := output[p.Name].(map[string]interface{})
+		m, _ := into[p.Name].(map[string]interface{})
+		values := []string{}
+		for value := range m {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		sort.Strings(values)
+		factors[p.Name] = values
+	}
+	return factors, nil
+}
+
+// load retrieves the table's properties if they are missing or expired. The
+// entry's lock must be held.
+func (s *schema) load(t *Table, ttl time.Duration) error {
+	if s.properties != nil && time.Now().Before(s.expires) {
+		return nil
+	}
+	properties, err := t.Properties()
+	if err != nil {
+		return err
+	}
+	s.properties, s.expires = properties, time.Now().Add(ttl)
+	s.factors = nil
+	return nil
+}
+
+// schemaEntry returns the table's entry in the schema cache, adding an empty
+// entry if there is none.
+func (t *Table) schemaEntry() *schema {
+	t.Client.schemaMutex.Lock()
+	defer t.Client.schemaMutex.Unlock()
+	if t.Client.schemas == nil {
+		t.Client.schemas = map[string]*schema{}
+	}
+	s := t.Client.schemas[t.Name]
+	if s == nil {
+		s = &schema{}
+		t.Client.schemas[t.Name] = s
+	}
+	return s
+}
+
+// schemaTTL returns how long table schemas are cached. A negative duration
+// disables the cache.
+func (c *Client) schemaTTL() time.Duration {
+	if c.SchemaTTL == 0 {
+		return DefaultSchemaTTL
+	}
+	return c.SchemaTTL
+}
+
+// invalidateSchema removes the table's properties from the schema cache.
+func (t *Table) invalidateSchema() {
+	t.Client.invalidateSchema(t.Name)
+}
+
+// refreshSchemaOn invalidates the table's cached properties if err is a
+// client error from the server, which may be caused by a stale schema.
+func (t *Table) refreshSchemaOn(err error) {
+	if err, ok := err.(*APIError); ok && err.StatusCode >= 400 && err.StatusCode < 500 {
+		t.invalidateSchema()
+	}
+}
+
+// invalidateSchema removes a table's properties from the schema cache.
+func (c *Client) invalidateSchema(name string) {
+	c.schemaMutex.Lock()
+	delete(c.schemas, name)
+	c.schemaMutex.Unlock()
+}
+
+// copyProperties returns a copy of a property list so that callers cannot
+// modify the cached properties.
+func copyProperties(properties []*Property) []*Property {
+	other := make([]*Property, len(properties))
+	for i, p := range properties {
+		property := *p
+		other[i] = &property
+	}
+	return other
+}
+
+// copyFactorValues returns a copy of cached factor values so that callers
+// cannot modify them.
+func copyFactorValues(factors map[string][]string) map[string][]string {
+	other := make(map[string][]string, len(factors))
+	for name, values := range factors {
+		other[name] = append([]string{}, values...)
+	}
+	return other
+}
@@ -0,0 +1,126 @@
+package sky
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that properties are cached until they are changed, expire or a
+// request fails with a client error.
+func TestTableSchema(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tables/t0/properties" && r.Method == "GET":
+			atomic.AddInt32(&requests, 1)
+			w.Write([]byte(`[{"name":"action","transient":true,"dataType":"factor"}]`))
+		case r.URL.Path == "/tables/t0/query":
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	table := &Table{Client: c, Name: "t0"}
+
+	// Concurrent readers share a single request.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			properties, err := table.Schema()
+			assert.NoError(t, err)
+			assert.Equal(t, properties[0].Name, "action")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// Cached properties cannot be modified by callers.
+	properties, _ := table.Schema()
+	properties[0].Name = "x"
+	properties, _ = (&Table{Client: c, Name: "t0"}).Schema()
+	assert.Equal(t, properties[0].Name, "action")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// Property changes invalidate the cache.
+	assert.NoError(t, table.CreateProperty(&Property{Name: "price", DataType: Float}))
+	table.Schema()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+
+	// Client errors invalidate the cache.
+	_, err := table.Query("SELECT count()")
+	assert.Error(t, err)
+	table.Schema()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(3))
+
+	// Expired properties are retrieved again.
+	c.SchemaTTL = time.Nanosecond
+	c.invalidateSchema("t0")
+	table.Schema()
+	time.Sleep(time.Millisecond)
+	table.Schema()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(5))
+
+	// A negative TTL disables caching.
+	c.SchemaTTL = -1
+	table.Schema()
+	table.Schema()
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(7))
+}
+
+// Ensure that factor values are loaded with a single query, cached with the
+// properties and passed to the query validator.
+func TestTableFactorValues(t *testing.T) {
+	var queries []string
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tables/t0/properties" && r.Method == "GET":
+			w.Write([]byte(`[{"name":"action","transient":true,"dataType":"factor"},{"name":"price","transient":true,"dataType":"float"},{"name":"bad-name","transient":false,"dataType":"factor"}]`))
+		case r.URL.Path == "/tables/t0/query":
+			query, _ := io.ReadAll(r.Body)
+			mutex.Lock()
+			queries = append(queries, string(query))
+			mutex.Unlock()
+			w.Write([]byte(`{"action":{"action":{"cart":{"count":1},"home":{"count":2},"":{"count":3}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	table := &Table{Client: c, Name: "t0"}
+
+	factors, err := table.FactorValues()
+	assert.NoError(t, err)
+	assert.Equal(t, factors, map[string][]string{"action": {"cart", "home"}})
+	factors["action"][0] = "x"
+	factors, _ = table.FactorValues()
+	assert.Equal(t, factors, map[string][]string{"action": {"cart", "home"}})
+	assert.Equal(t, queries, []string{"SELECT count() GROUP BY action INTO \"action\"\n"})
+
+	// The validator receives the cached values and the query is sent after.
+	var validated map[string][]string
+	c.QueryValidator = func(query string, properties []*Property, factorValues map[string][]string) error {
+		validated = factorValues
+		return nil
+	}
+	_, err = table.Query("SELECT count()")
+	assert.NoError(t, err)
+	assert.Equal(t, validated, map[string][]string{"action": {"cart", "home"}})
+	assert.Equal(t, len(queries), 2)
+
+	// Invalidating the schema reloads the values.
+	c.invalidateSchema("t0")
+	table.FactorValues()
+	assert.Equal(t, len(queries), 3)
+}
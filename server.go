@@ -0,0 +1,154 @@
+package sky
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CapabilityBulkEvents is supported by servers that accept multiple
+	// events in a single PATCH to /events or /tables/:name/events.
+	CapabilityBulkEvents = "bulk_events"
+
+	// CapabilityEventRange is supported by servers that can filter an
+	// object's events by time with "start" and "end" query parameters.
+	CapabilityEventRange = "event_range"
+
+	// CapabilityPaging is supported by servers that can return tables and
+	// properties in pages. A paged request has a "limit" query parameter
+	// with the page size and may have "prefix" and "after" parameters. The
+	// server returns at most limit items in name order whose names start
+	// with prefix and sort after the "after" name. A page with fewer than
+	// limit items is the last.
+	CapabilityPaging = "paging"
+)
+
+// legacyCapabilities are assumed for servers that do not report their
+// capabilities.
+var legacyCapabilities = []string{CapabilityBulkEvents}
+
+// ServerInfo describes the version and capabilities of a Sky server.
+type ServerInfo struct {
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Supports returns true if the server has the given capability.
+func (i *ServerInfo) Supports(capability string) bool {
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionError is returned when the server's version is not compatible with
+// the version this library is meant to work with.
+type VersionError struct {
+	ServerVersion string
+	ClientVersion string
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("incompatible server version: %s (client supports %s)", e.ServerVersion, e.ClientVersion)
+}
+
+// ServerInfo retrieves the version and capabilities of the server. The
+// result is cached on the client. Servers that do not have an info endpoint
+// are returned with a blank version and the legacy capabilities. Other errors
+// are returned and the server is asked again on the next call.
+func (c *Client) ServerInfo() (*ServerInfo, error) {
+	c.mutex.Lock()
+	info := c.serverInfo
+	c.mutex.Unlock()
+	if info != nil {
+		return info, nil
+	}
+
+	info = &ServerInfo{}
+	if err := c.Send("GET", "/", nil, info); err != nil {
+		if err, ok := err.(*APIError); !(ok && err.StatusCode == http.StatusNotFound) && !isDecodeError(err) {
+			return nil, err
+		}
+		info = &ServerInfo{}
+	}
+	if info.Capabilities == nil {
+		info.Capabilities = legacyCapabilities
+	}
+
+	// Keep the first result if another call finished first.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.serverInfo == nil {
+		c.serverInfo = info
+	}
+	return c.serverInfo, nil
+}
+
+// CheckCompatibility compares the server's version against Version. A
+// different major or minor version returns a *VersionError. A different patch
+// version or an unknown server version is logged as a warning.
+func (c *Client) CheckCompatibility() error {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return err
+	}
+	server, ok := parseVersion(info.Version)
+	if !ok {
+		c.logger().Warn("sky: unknown server version", "version", info.Version, "client_version", Version)
+		return nil
+	}
+	client, _ := parseVersion(Version)
+	if server[0] != client[0] || server[1] != client[1] {
+		return &VersionError{ServerVersion: info.Version, ClientVersion: Version}
+	} else if server[2] != client[2] {
+		c.logger().Warn("sky: server patch version differs", "version", info.Version, "client_version", Version)
+	}
+	return nil
+}
+
+// supports returns true if the server has a capability. If the server cannot
+// be reached then the capability is assumed so that the caller's request
+// returns the underlying error.
+func (c *Client) supports(capability string) bool {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return true
+	}
+	return info.Supports(capability)
+}
+
+// parseVersion parses a "major.minor.patch" version. A "v" prefix and any
+// pre-release suffix are ignored.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i != -1 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// isDecodeError returns true if err occurred while decoding a JSON response.
+func isDecodeError(err error) bool {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package sky
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that server info is retrieved and cached.
+func TestClientServerInfo(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"sky":"welcome","version":"0.4.2","capabilities":["event_range"]}`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	info, err := c.ServerInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, info.Version, "0.4.2")
+	assert.True(t, info.Supports(CapabilityEventRange))
+	assert.False(t, info.Supports(CapabilityBulkEvents))
+	assert.NoError(t, c.CheckCompatibility())
+	assert.Equal(t, requests, 1)
+}
+
+// Ensure that older servers fall back to the legacy capabilities.
+func TestClientServerInfoLegacy(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	info, err := c.ServerInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, info.Version, "")
+	assert.True(t, info.Supports(CapabilityBulkEvents))
+	assert.NoError(t, c.CheckCompatibility())
+}
+
+// Ensure that failed requests are not cached as legacy servers.
+func TestClientServerInfoError(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"version":"0.4.0","capabilities":[]}`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	_, err := c.ServerInfo()
+	assert.Equal(t, err.(*APIError).StatusCode, http.StatusServiceUnavailable)
+
+	status = http.StatusOK
+	info, err := c.ServerInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, info.Version, "0.4.0")
+	assert.False(t, info.Supports(CapabilityBulkEvents))
+}
+
+// Ensure that names containing a question mark are escaped in the path.
+func TestClientURL(t *testing.T) {
+	var uri string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uri = r.RequestURI
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	_, err := (&Table{Client: c, Name: "t0"}).Events("a?b=c")
+	assert.NoError(t, err)
+	assert.Equal(t, uri, "/tables/t0/objects/a%3Fb=c/events")
+	assert.Equal(t, c.queryURL("/tables", url.Values{"limit": {"10"}}).RequestURI(), "/tables?limit=10")
+}
+
+// Ensure that a different minor version is incompatible.
+func TestClientCheckCompatibility(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"v0.5.0-rc1"}`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	err := c.CheckCompatibility()
+	assert.Equal(t, err, &VersionError{ServerVersion: "v0.5.0-rc1", ClientVersion: Version})
+}
+
+// Ensure that event ranges are filtered by the server when supported.
+func TestTableEventsBetween(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version":"0.4.0","capabilities":["event_range"]}`))
+			return
+		}
+		query = r.URL.RawQuery
+		w.Write([]byte(`[{"timestamp":"1970-01-01T00:00:00Z"},{"timestamp":"1970-01-01T00:00:01Z"},{"timestamp":"1970-01-01T00:00:02Z"}]`))
+	}))
+	defer server.Close()
+
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	start, _ := ParseTimestamp("1970-01-01T00:00:01Z")
+	events, err := (&Table{Client: c, Name: "t0"}).EventsBetween("o0", start, start.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, query, "end=1970-01-01T00%3A00%3A02Z&start=1970-01-01T00%3A00%3A01Z")
+	if assert.Equal(t, len(events), 1) {
+		assert.Equal(t, events[0].Timestamp, start)
+	}
+
+	// A zero end leaves the range open.
+	events, err = (&Table{Client: c, Name: "t0"}).EventsBetween("o0", start, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, query, "start=1970-01-01T00%3A00%3A01Z")
+	assert.Equal(t, len(events), 2)
+}
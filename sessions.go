@@ -0,0 +1,101 @@
+package sky
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SessionStats describes the sessions on a table.
+type SessionStats struct {
+	Count int `json:"count"`
+
+	// Duration is the total time between the first and last event of each
+	// session. Durations are measured in whole seconds.
+	Duration    time.Duration `json:"duration"`
+	MinDuration time.Duration `json:"minDuration"`
+	MaxDuration time.Duration `json:"maxDuration"`
+
+	// Lengths is the number of sessions with each number of events.
+	Lengths map[int]int `json:"lengths"`
+
+	// Durations is the number of sessions with each duration.
+	Durations map[time.Duration]int `json:"durations"`
+}
+
+// MeanDuration returns the average session duration.
+func (s *SessionStats) MeanDuration() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Count)
+}
+
+// Sessions splits each object's events into sessions delimited by idle time
+// and returns the distribution of session lengths and durations.
+func (t *Table) Sessions(idleTimeout time.Duration) (*SessionStats, error) {
+	q, err := SessionsQuery(idleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.Query(q)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &SessionStats{Lengths: map[int]int{}, Durations: map[time.Duration]int{}}
+	count, _ := output["sessions"].(float64)
+	stats.Count = int(count)
+	stats.Duration = floatSeconds(output["duration"])
+	stats.MinDuration = floatSeconds(output["minDuration"])
+	stats.MaxDuration = floatSeconds(output["maxDuration"])
+
+	lengths, _ := output["lengths"].(map[string]interface{})
+	m, _ := lengths["session_events"].(map[string]interface{})
+	for key, v := range m {
+		if n, err := strconv.Atoi(key); err == nil {
+			count, _ := v.(map[string]interface{})["sessions"].(float64)
+			stats.Lengths[n] = int(count)
+		}
+	}
+
+	durations, _ := output["durations"].(map[string]interface{})
+	m, _ = durations["session_duration"].(map[string]interface{})
+	for key, v := range m {
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil {
+			count, _ := v.(map[string]interface{})["sessions"].(float64)
+			stats.Durations[time.Duration(n)*time.Second] = int(count)
+		}
+	}
+	return stats, nil
+}
+
+// SessionsQuery generates the SkyQL used by Table.Sessions.
+func SessionsQuery(idleTimeout time.Duration) (string, error) {
+	if idleTimeout < time.Second {
+		return "", ErrSessionTimeoutRequired
+	}
+	return fmt.Sprintf(`DECLARE session_events AS INTEGER
+DECLARE session_start AS INTEGER
+DECLARE session_duration AS INTEGER
+FOR EACH SESSION DELIMITED BY %d SECONDS
+  WHEN session_events == 0 THEN
+    SET session_start = @timestamp
+  END
+  SET session_events = session_events + 1
+  WHEN @eos THEN
+    SET session_duration = @timestamp - session_start
+    SELECT count() AS sessions, sum(session_duration) AS duration, min(session_duration) AS minDuration, max(session_duration) AS maxDuration
+    SELECT count() AS sessions GROUP BY session_events INTO "lengths"
+    SELECT count() AS sessions GROUP BY session_duration INTO "durations"
+    SET session_events = 0
+  END
+END
+`, int64(idleTimeout/time.Second)), nil
+}
+
+// floatSeconds converts a number of seconds from a query result to a duration.
+func floatSeconds(v interface{}) time.Duration {
+	f, _ := v.(float64)
+	return time.Duration(f * float64(time.Second))
+}
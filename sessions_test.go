@@ -0,0 +1,28 @@
+package sky_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that sessions are counted with their length and duration.
+func TestTableSessions(t *testing.T) {
+	server, table := newQueryServer(t, analyticsProperties, analyticsEvents())
+	defer server.Close()
+
+	stats, err := table.Sessions(30 * time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Count, 4)
+	assert.Equal(t, stats.Duration, 3*time.Minute)
+	assert.Equal(t, stats.MinDuration, time.Duration(0))
+	assert.Equal(t, stats.MaxDuration, 2*time.Minute)
+	assert.Equal(t, stats.MeanDuration(), 45*time.Second)
+	assert.Equal(t, stats.Lengths, map[int]int{1: 2, 2: 1, 3: 1})
+	assert.Equal(t, stats.Durations, map[time.Duration]int{0: 2, time.Minute: 1, 2 * time.Minute: 1})
+
+	_, err = table.Sessions(0)
+	assert.Equal(t, err, sky.ErrSessionTimeoutRequired)
+}
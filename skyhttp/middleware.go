@@ -0,0 +1,186 @@
+package skyhttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+
+// IDExtractor returns the object identifier for a request. An empty
+// identifier means the request is not recorded.
+type IDExtractor func(r *http.Request) string
+
+// Cookie returns an extractor that reads the object identifier from a cookie.
+func Cookie(name string) IDExtractor {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// Header returns an extractor that reads the object identifier from a
+// request header.
+func Header(name string) IDExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// ContextValue returns an extractor that reads the object identifier from the
+// request's context, such as a user id set by an authentication handler.
+// Values that are not strings are formatted with fmt.
+func ContextValue(key interface{}) IDExtractor {
+	return func(r *http.Request) string {
+		switch v := r.Context().Value(key).(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+}
+
+// First returns an extractor that uses the first non-empty identifier from a
+// list of extractors.
+func First(extractors ...IDExtractor) IDExtractor {
+	return func(r *http.Request) string {
+		for _, fn := range extractors {
+			if id := fn(r); id != "" {
+				return id
+			}
+		}
+		return ""
+	}
+}
+
+// Recorder records an event for every request handled by a server:
+//
+//	method   the request method
+//	path     the path template, or the request path
+//	status   the response status code
+//	latency  the time spent in the handler, in milliseconds
+//
+// Events are sent through a producer so that requests never wait on the Sky
+// server. The producer can be shared by several recorders.
+type Recorder struct {
+	// Producer sends the events. Required.
+	Producer *sky.Producer
+
+	// ID returns the object identifier for a request. Required.
+	ID IDExtractor
+
+	// PathTemplate returns the path recorded for a request, such as
+	// "/users/:id". Defaults to the request path.
+	PathTemplate func(r *http.Request) string
+
+	// Fields returns additional data to record for a request.
+	Fields func(r *http.Request) map[string]interface{}
+}
+
+// Handler returns a handler that records requests to next. An identifier
+// set by next with SetID is recorded instead of the extracted one.
+func (rec *Recorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t0 := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		holder := &idHolder{}
+		r = r.WithContext(context.WithValue(r.Context(), idHolderKey{}, holder))
+		next.ServeHTTP(sw, r)
+
+		// The id is read afterward so that handlers can set it with SetID.
+		id := holder.id
+		if id == "" {
+			id = rec.ID(r)
+		}
+		if id == "" {
+			return
+		}
+		rec.Producer.Send(id, rec.event(r, sw.status, t0))
+	})
+}
+
+// idHolder holds the identifier set by a handler for the current request.
+type idHolder struct {
+	id string
+}
+
+type idHolderKey struct{}
+
+// SetID sets the object identifier recorded for a request by a Recorder, such
+// as the user id from a login handler. It must be called before the handler
+// returns and has no effect on requests that are not handled by a Recorder.
+func SetID(r *http.Request, id string) {
+	if h, ok := r.Context().Value(idHolderKey{}).(*idHolder); ok {
+		h.id = id
+	}
+}
+
+// event builds the event for a request.
+func (rec *Recorder) event(r *http.Request, status int, t0 time.Time) *sky.Event {
+	data := map[string]interface{}{}
+	if rec.Fields != nil {
+		for k, v := range rec.Fields(r) {
+			data[k] = v
+		}
+	}
+	path := r.URL.Path
+	if rec.PathTemplate != nil {
+		path = rec.PathTemplate(r)
+	}
+	data["method"] = r.Method
+	data["path"] = path
+	data["status"] = status
+	data["latency"] = float64(time.Since(t0)) / float64(time.Millisecond)
+	return &sky.Event{Timestamp: t0, Data: data}
+}
+
+// statusWriter records the status code written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client if the underlying writer supports it.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the underlying writer supports it.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("skyhttp: response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,169 @@
+package skyhttp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSkyServer returns a server that records events streamed to it. The
+// returned function waits for a stream to end before returning the events.
+func newSkyServer(t *testing.T) (*httptest.Server, func() map[string][]map[string]interface{}) {
+	var mutex sync.Mutex
+	events := map[string][]map[string]interface{}{}
+	closed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streams send a chunked body over the hijacked connection.
+		if r.Proto == "HTTP/1.0" {
+			defer func() { closed <- struct{}{} }()
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			scanner := bufio.NewScanner(httputil.NewChunkedReader(rw))
+			for scanner.Scan() {
+				var m map[string]interface{}
+				json.Unmarshal(scanner.Bytes(), &m)
+				id, _ := m["id"].(string)
+				mutex.Lock()
+				events[id] = append(events[id], m["data"].(map[string]interface{}))
+				mutex.Unlock()
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return server, func() map[string][]map[string]interface{} {
+		<-closed
+		mutex.Lock()
+		defer mutex.Unlock()
+		return events
+	}
+}
+
+// Ensure that identifiers are extracted from cookies, headers and contexts.
+func TestIDExtractor(t *testing.T) {
+	type key struct{}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "visitor", Value: "v1"})
+	r.Header.Set("X-User", "u1")
+	assert.Equal(t, Cookie("visitor")(r), "v1")
+	assert.Equal(t, Cookie("session")(r), "")
+	assert.Equal(t, Header("X-User")(r), "u1")
+	assert.Equal(t, ContextValue(key{})(r), "")
+	assert.Equal(t, ContextValue(key{})(r.WithContext(context.WithValue(r.Context(), key{}, 42))), "42")
+	assert.Equal(t, First(ContextValue(key{}), Cookie("visitor"), Header("X-User"))(r), "v1")
+	assert.Equal(t, First(Cookie("session"))(r), "")
+}
+
+// Ensure that requests are recorded as events.
+func TestRecorderHandler(t *testing.T) {
+	server, events := newSkyServer(t)
+	defer server.Close()
+
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	p := sky.NewProducer(&sky.Table{Client: c, Name: "t0"}, 0)
+	rec := &Recorder{
+		Producer:     p,
+		ID:           Header("X-User"),
+		PathTemplate: func(r *http.Request) string { return "/users/:id" },
+		Fields: func(r *http.Request) map[string]interface{} {
+			return map[string]interface{}{"agent": r.UserAgent(), "status": "overridden"}
+		},
+	}
+	h := rec.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest("DELETE", "/users/1", nil)
+	r.Header.Set("X-User", "u1")
+	r.Header.Set("User-Agent", "test")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	// Requests without an identifier are not recorded.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.NoError(t, p.Close())
+	assert.Equal(t, p.Dropped(), uint64(0))
+	m := events()
+	assert.Equal(t, len(m), 1)
+	if assert.Equal(t, len(m["u1"]), 1) {
+		data := m["u1"][0]
+		assert.Equal(t, data["method"], "DELETE")
+		assert.Equal(t, data["path"], "/users/:id")
+		assert.Equal(t, data["status"], float64(404))
+		assert.Equal(t, data["agent"], "test")
+		_, ok := data["latency"].(float64)
+		assert.True(t, ok)
+	}
+}
+
+// Ensure that handlers can set the identifier of the request they handle.
+func TestRecorderHandlerSetID(t *testing.T) {
+	server, events := newSkyServer(t)
+	defer server.Close()
+
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	p := sky.NewProducer(&sky.Table{Client: c, Name: "t0"}, 0)
+	rec := &Recorder{Producer: p, ID: Header("X-User")}
+	h := rec.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetID(r, "u2")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/login", nil))
+
+	// Requests outside of a recorder are unaffected.
+	SetID(httptest.NewRequest("GET", "/", nil), "u3")
+
+	assert.NoError(t, p.Close())
+	m := events()
+	assert.Equal(t, len(m), 1)
+	if assert.Equal(t, len(m["u2"]), 1) {
+		assert.Equal(t, m["u2"][0]["path"], "/login")
+	}
+}
+
+// Ensure that recorded handlers can flush and hijack their responses.
+func TestRecorderHandlerHijack(t *testing.T) {
+	rec := &Recorder{ID: func(r *http.Request) string { return "" }}
+	server := httptest.NewServer(rec.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, string(b), "hijacked")
+
+	// Flushes are passed on and writers without hijacking support return an
+	// error.
+	w := httptest.NewRecorder()
+	var sw http.ResponseWriter = &statusWriter{ResponseWriter: w}
+	sw.(http.Flusher).Flush()
+	assert.True(t, w.Flushed)
+	_, _, err = sw.(http.Hijacker).Hijack()
+	assert.Error(t, err)
+}
@@ -0,0 +1,273 @@
+package skylog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+
+// Options configures a Handler.
+type Options struct {
+	// IDKey is the attribute holding the object identifier. Attributes in
+	// groups are named with the group names joined by dots, such as
+	// "user.id". Records without the attribute are not sent. Required.
+	IDKey string
+
+	// Level is the minimum level of records that are sent. Defaults to
+	// slog.LevelInfo.
+	Level slog.Leveler
+
+	// Names maps attribute names to property names. Attributes that are not
+	// mapped use their own names. The message and level are the "msg" and
+	// "level" attributes.
+	Names map[string]string
+
+	// SchemaTTL is how long the table's properties are used before they are
+	// refreshed in the background. Defaults to sky.DefaultSchemaTTL.
+	SchemaTTL time.Duration
+}
+
+// Handler is a slog.Handler that sends log records as events through a
+// producer. Attribute values are converted to the data types of the table's
+// properties and attributes without a property are ignored. Records are
+// dropped when the producer's queue is full.
+//
+// Records logged by the sky package itself are ignored so the producer's
+// client can log to the handler without its warnings about dropped events
+// being sent back through the producer.
+type Handler struct {
+	producer *sky.Producer
+	opts     Options
+	attrs    []slog.Attr
+	prefix   string
+	schema   *schema
+}
+
+// NewHandler returns a handler that sends records through a producer. The
+// table's properties are read before it returns.
+func NewHandler(p *sky.Producer, opts *Options) (*Handler, error) {
+	h := &Handler{producer: p}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.SchemaTTL <= 0 {
+		h.opts.SchemaTTL = sky.DefaultSchemaTTL
+	}
+	properties, err := p.Table().Properties()
+	if err != nil {
+		return nil, err
+	}
+	h.schema = &schema{table: p.Table(), ttl: h.opts.SchemaTTL, properties: properties, updated: time.Now()}
+	return h, nil
+}
+
+// Enabled reports whether records at a level are sent.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle converts a record to an event and queues it on the producer.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	if internal(r.PC) {
+		return nil
+	}
+
+	var id string
+	data := map[string]interface{}{}
+	h.add(data, &id, "", slog.String(slog.MessageKey, r.Message))
+	h.add(data, &id, "", slog.String(slog.LevelKey, r.Level.String()))
+	for _, a := range h.attrs {
+		h.add(data, &id, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.add(data, &id, h.prefix, a)
+		return true
+	})
+	if id == "" {
+		return nil
+	}
+
+	properties := h.schema.get()
+	e := &sky.Event{Timestamp: r.Time, Data: map[string]interface{}{}}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	for _, p := range properties {
+		if v, ok := coerce(data[p.Name], p.DataType); ok {
+			e.Data[p.Name] = v
+		}
+	}
+	h.producer.Send(id, e)
+	return nil
+}
+
+// WithAttrs returns a handler that adds attributes to every record.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	other := *h
+	other.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		if h.prefix != "" {
+			a = slog.Group(h.prefix[:len(h.prefix)-1], a)
+		}
+		other.attrs = append(other.attrs, a)
+	}
+	return &other
+}
+
+// WithGroup returns a handler that adds a group to the names of the record's
+// attributes.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	other := *h
+	other.prefix = h.prefix + name + "."
+	return &other
+}
+
+// schema holds the table's properties for a handler and the handlers derived
+// from it. Expired properties are used until a refresh succeeds.
+type schema struct {
+	table      *sky.Table
+	ttl        time.Duration
+	mutex      sync.Mutex
+	properties []*sky.Property
+	updated    time.Time
+	refreshing bool
+}
+
+// get returns the current properties and starts a refresh if they expired.
+func (s *schema) get() []*sky.Property {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.refreshing && time.Since(s.updated) > s.ttl {
+		s.refreshing = true
+		go s.refresh()
+	}
+	return s.properties
+}
+
+// refresh reads the table's properties. Errors are not logged since the
+// client's logger may be this handler.
+func (s *schema) refresh() {
+	properties, err := s.table.Properties()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.properties = properties
+	}
+	s.updated, s.refreshing = time.Now(), false
+}
+
+// skyPackage is the import path of the sky package.
+var skyPackage = reflect.TypeOf(sky.Event{}).PkgPath()
+
+// internal returns true if a record was logged from the sky package.
+func internal(pc uintptr) bool {
+	if pc == 0 {
+		return false
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return strings.HasPrefix(frame.Function, skyPackage+".")
+}
+
+// add adds an attribute to the event data or sets the object identifier.
+// Groups are flattened into dotted names.
+func (h *Handler) add(data map[string]interface{}, id *string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, attr := range a.Value.Group() {
+			h.add(data, id, prefix, attr)
+		}
+		return
+	}
+
+	name := prefix + a.Key
+	if name == h.opts.IDKey {
+		*id = a.Value.String()
+		return
+	}
+	if other, ok := h.opts.Names[name]; ok {
+		name = other
+	}
+	data[name] = a.Value.Any()
+}
+
+// coerce converts a value to the Go type of a property data type. Returns
+// false if the value is missing or cannot be converted.
+func coerce(v interface{}, dataType string) (interface{}, bool) {
+	switch dataType {
+	case sky.Integer:
+		switch v := v.(type) {
+		case int64:
+			return v, true
+		case uint64:
+			return int64(v), v <= math.MaxInt64
+		case float64:
+			return int64(v), v == math.Trunc(v) && math.Abs(v) < math.MaxInt64
+		case bool:
+			if v {
+				return int64(1), true
+			}
+			return int64(0), true
+		case time.Duration:
+			return int64(v / time.Millisecond), true
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			return i, err == nil
+		}
+	case sky.Float:
+		switch v := v.(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case time.Duration:
+			return float64(v) / float64(time.Millisecond), true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+	case sky.Boolean:
+		switch v := v.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+	default:
+		switch v := v.(type) {
+		case nil:
+		case string:
+			return v, true
+		case time.Time:
+			return sky.FormatTimestamp(v), true
+		case fmt.Stringer:
+			return v.String(), true
+		default:
+			return fmt.Sprint(v), true
+		}
+	}
+	return nil, false
+}
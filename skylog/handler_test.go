@@ -0,0 +1,207 @@
+package skylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSkyServer returns a server with a table t0 that records events streamed
+// to it. The returned function waits for a stream to end before returning the
+// events.
+func newSkyServer(t *testing.T) (*httptest.Server, func() map[string][]map[string]interface{}) {
+	var mutex sync.Mutex
+	events := map[string][]map[string]interface{}{}
+	closed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streams send a chunked body over the hijacked connection.
+		if r.Proto == "HTTP/1.0" {
+			defer func() { closed <- struct{}{} }()
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			scanner := bufio.NewScanner(httputil.NewChunkedReader(rw))
+			for scanner.Scan() {
+				var m map[string]interface{}
+				json.Unmarshal(scanner.Bytes(), &m)
+				id, _ := m["id"].(string)
+				mutex.Lock()
+				events[id] = append(events[id], m)
+				mutex.Unlock()
+			}
+			return
+		}
+		switch {
+		case r.URL.Path == "/tables/t0/properties":
+			w.Write([]byte(`[
+				{"name":"message","dataType":"string"},
+				{"name":"level","dataType":"factor"},
+				{"name":"bytes","dataType":"integer"},
+				{"name":"req.latency","dataType":"float"},
+				{"name":"cached","dataType":"boolean"},
+				{"name":"error","dataType":"string"}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, func() map[string][]map[string]interface{} {
+		<-closed
+		mutex.Lock()
+		defer mutex.Unlock()
+		return events
+	}
+}
+
+// Ensure that log records are sent as events with converted values.
+func TestHandler(t *testing.T) {
+	server, events := newSkyServer(t)
+	defer server.Close()
+
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	p := sky.NewProducer(&sky.Table{Client: c, Name: "t0"}, 0)
+	h, err := NewHandler(p, &Options{
+		IDKey: "user.id",
+		Names: map[string]string{"msg": "message"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger := slog.New(h)
+
+	logger.Debug("ignored", "user", slog.GroupValue(slog.String("id", "u1")))
+	logger.Info("no user")
+	logger.With("cached", "true", slog.Group("user", "id", "u3")).WithGroup("req").Warn("request",
+		"latency", 1500*time.Microsecond,
+		slog.Group("", slog.Int("unknown", 1)),
+	)
+	logger.WithGroup("user").Info("ignored", "id", "u2", "bytes", "x")
+	logger.Error("failed",
+		slog.Group("user", slog.Int("id", 1)),
+		"bytes", 1024.0,
+		"error", errors.New("timeout"),
+	)
+
+	assert.NoError(t, p.Close())
+	m := events()
+	assert.Equal(t, len(m), 3)
+	if assert.Equal(t, len(m["u2"]), 1) {
+		assert.Equal(t, m["u2"][0]["data"], map[string]interface{}{"message": "ignored", "level": "INFO"})
+	}
+	if assert.Equal(t, len(m["u3"]), 1) {
+		assert.Equal(t, m["u3"][0]["data"], map[string]interface{}{
+			"message":     "request",
+			"level":       "WARN",
+			"cached":      true,
+			"req.latency": 1.5,
+		})
+	}
+	if assert.Equal(t, len(m["1"]), 1) {
+		assert.Equal(t, m["1"][0]["data"], map[string]interface{}{
+			"message": "failed",
+			"level":   "ERROR",
+			"bytes":   float64(1024),
+			"error":   "timeout",
+		})
+	}
+}
+
+// Ensure that the schema is read when the handler is created and refreshed in
+// the background after it expires.
+func TestHandlerSchema(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tables/t0/properties" {
+			atomic.AddInt32(&requests, 1)
+			w.Write([]byte(`[{"name":"message","dataType":"string"}]`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	p := sky.NewProducer(&sky.Table{Client: c, Name: "t0"}, 0)
+	defer p.Close()
+	h, err := NewHandler(p, &Options{IDKey: "id", SchemaTTL: 20 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger := slog.New(h).With("id", "u1")
+	logger.Info("a")
+	logger.Info("b")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	time.Sleep(30 * time.Millisecond)
+	logger.Info("c")
+	for i := 0; i < 100 && atomic.LoadInt32(&requests) < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+
+	// A missing table fails when the handler is created.
+	_, err = NewHandler(sky.NewProducer(&sky.Table{Client: c, Name: "t1"}, 0), nil)
+	assert.Error(t, err)
+}
+
+// Ensure that the producer's warnings are not sent back through the producer
+// when the client logs to the handler.
+func TestHandlerClientLogger(t *testing.T) {
+	server, _ := newSkyServer(t)
+
+	c := &sky.Client{Host: strings.TrimPrefix(server.URL, "http://")}
+	p := sky.NewProducer(&sky.Table{Client: c, Name: "t0"}, 0)
+	h, err := NewHandler(p, &Options{IDKey: "id"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.Logger = slog.New(h)
+	server.Close()
+
+	c.Logger.Info("lost", "id", "u1")
+	p.Close()
+	assert.Equal(t, p.Dropped(), uint64(1))
+}
+
+// Ensure that values are converted to property data types.
+func TestCoerce(t *testing.T) {
+	var tests = []struct {
+		value    interface{}
+		dataType string
+		expected interface{}
+		ok       bool
+	}{
+		{int64(2), sky.Integer, int64(2), true},
+		{2.5, sky.Integer, nil, false},
+		{"12", sky.Integer, int64(12), true},
+		{time.Second, sky.Integer, int64(1000), true},
+		{int64(2), sky.Float, float64(2), true},
+		{"x", sky.Float, nil, false},
+		{"true", sky.Boolean, true, true},
+		{int64(1), sky.Boolean, nil, false},
+		{int64(1), sky.Factor, "1", true},
+		{time.Unix(0, 0), sky.String, "1970-01-01T00:00:00Z", true},
+		{nil, sky.String, nil, false},
+	}
+	for _, test := range tests {
+		v, ok := coerce(test.value, test.dataType)
+		assert.Equal(t, ok, test.ok, "%v %s", test.value, test.dataType)
+		if ok {
+			assert.Equal(t, v, test.expected, "%v %s", test.value, test.dataType)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package skyql
+
+import (
+	"time"
+)
+
+// Node is an element of a parsed query.
+type Node interface {
+	Pos() Pos
+}
+
+// Statement is a node that can appear in a statement block.
+type Statement interface {
+	Node
+	stmt()
+}
+
+// Expr is a node that evaluates to a value.
+type Expr interface {
+	Node
+	expr()
+}
+
+// Query is a parsed SkyQL query. The top-level statements are executed once
+// for every event of every object.
+type Query struct {
+	Statements []Statement
+}
+
+// Declaration declares a variable that holds its value across the events of
+// an object:
+//
+//	DECLARE name AS INTEGER
+type Declaration struct {
+	pos      Pos
+	Name     string
+	DataType string
+}
+
+// Assignment sets the value of a declared variable:
+//
+//	SET name = expr
+type Assignment struct {
+	pos  Pos
+	Name string
+	Expr Expr
+}
+
+// Selection aggregates the current event into the results:
+//
+//	SELECT count(), sum(price) AS revenue GROUP BY action WHERE price > 0 INTO "x"
+type Selection struct {
+	pos        Pos
+	Fields     []*Field
+	Dimensions []*Ident
+	Where      Expr
+	Into       string
+}
+
+// Field is a single aggregate in a selection.
+type Field struct {
+	pos       Pos
+	Aggregate string
+	Expr      Expr
+	Alias     string
+}
+
+// Name returns the key the field is stored under in the results.
+func (f *Field) Name() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Aggregate
+}
+
+// Condition executes its statements when its expression is true. If a WITHIN
+// range is given then the statements are executed at the first event in the
+// range that matches:
+//
+//	WHEN action == "checkout" WITHIN 1 .. 3 STEPS THEN ... END
+type Condition struct {
+	pos        Pos
+	Expr       Expr
+	Within     bool
+	Start, End int
+	Statements []Statement
+}
+
+// SessionLoop executes its statements within the session of the current
+// event. A session ends when the time between two events is greater than the
+// idle time. WITHIN ranges do not cross session boundaries and @eos is true on
+// the last event of each session:
+//
+//	FOR EACH SESSION DELIMITED BY 30 MINUTES ... END
+type SessionLoop struct {
+	pos        Pos
+	Idle       time.Duration
+	Statements []Statement
+}
+
+// BinaryExpr is an operation on two expressions.
+type BinaryExpr struct {
+	pos Pos
+	Op  Token
+	LHS Expr
+	RHS Expr
+}
+
+// UnaryExpr is an operation on a single expression.
+type UnaryExpr struct {
+	pos Pos
+	Op  Token
+	X   Expr
+}
+
+// Ident refers to a property or a declared variable.
+type Ident struct {
+	pos  Pos
+	Name string
+}
+
+// Var refers to a system variable such as @timestamp, @eos or @eof.
+type Var struct {
+	pos  Pos
+	Name string
+}
+
+// IntegerLiteral is an integer constant.
+type IntegerLiteral struct {
+	pos   Pos
+	Value int64
+}
+
+// FloatLiteral is a floating point constant.
+type FloatLiteral struct {
+	pos   Pos
+	Value float64
+}
+
+// StringLiteral is a quoted string constant.
+type StringLiteral struct {
+	pos   Pos
+	Value string
+}
+
+// BooleanLiteral is TRUE or FALSE.
+type BooleanLiteral struct {
+	pos   Pos
+	Value bool
+}
+
+func (n *Declaration) Pos() Pos    { return n.pos }
+func (n *Assignment) Pos() Pos     { return n.pos }
+func (n *Selection) Pos() Pos      { return n.pos }
+func (n *Field) Pos() Pos          { return n.pos }
+func (n *Condition) Pos() Pos      { return n.pos }
+func (n *SessionLoop) Pos() Pos    { return n.pos }
+func (n *BinaryExpr) Pos() Pos     { return n.pos }
+func (n *UnaryExpr) Pos() Pos      { return n.pos }
+func (n *Ident) Pos() Pos          { return n.pos }
+func (n *Var) Pos() Pos            { return n.pos }
+func (n *IntegerLiteral) Pos() Pos { return n.pos }
+func (n *FloatLiteral) Pos() Pos   { return n.pos }
+func (n *StringLiteral) Pos() Pos  { return n.pos }
+func (n *BooleanLiteral) Pos() Pos { return n.pos }
+
+func (*Declaration) stmt() {}
+func (*Assignment) stmt()  {}
+func (*Selection) stmt()   {}
+func (*Condition) stmt()   {}
+func (*SessionLoop) stmt() {}
+
+func (*BinaryExpr) expr()     {}
+func (*UnaryExpr) expr()      {}
+func (*Ident) expr()          {}
+func (*Var) expr()            {}
+func (*IntegerLiteral) expr() {}
+func (*FloatLiteral) expr()   {}
+func (*StringLiteral) expr()  {}
+func (*BooleanLiteral) expr() {}
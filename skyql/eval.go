@@ -0,0 +1,571 @@
+package skyql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+
+// Evaluate parses a query and executes it over a set of events. The results
+// have the same shape as the results returned by sky.Table.Query.
+func Evaluate(query string, properties []*sky.Property, events []sky.ObjectEvent) (map[string]interface{}, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Execute(properties, events)
+}
+
+// Execute runs the query over a set of events without a server. Events are
+// grouped by object and permanent property values carry forward between
+// events the same way they do on the server. All numeric results are returned
+// as float64, as they are when decoded from a server response.
+func (q *Query) Execute(properties []*sky.Property, events []sky.ObjectEvent) (map[string]interface{}, error) {
+	e := &executor{
+		properties: make(map[string]*sky.Property),
+		vars:       make(map[string]string),
+		results:    map[string]interface{}{},
+	}
+	for _, p := range properties {
+		e.properties[p.Name] = p
+	}
+	if err := e.declare(q.Statements); err != nil {
+		return nil, err
+	}
+
+	// Group events by object in the order the objects first appear.
+	ids := []string{}
+	objects := make(map[string][]*sky.Event)
+	for _, item := range events {
+		if item.Event == nil {
+			continue
+		}
+		if _, ok := objects[item.ID]; !ok {
+			ids = append(ids, item.ID)
+		}
+		objects[item.ID] = append(objects[item.ID], item.Event)
+	}
+
+	for _, id := range ids {
+		obj := &object{vars: make(map[string]interface{}), sessions: make(map[*SessionLoop][]session)}
+		sky.NewObjectState(objects[id], properties).Each(func(timestamp time.Time, state map[string]interface{}) {
+			obj.timestamps = append(obj.timestamps, timestamp)
+			obj.states = append(obj.states, state)
+		})
+		for name, dataType := range e.vars {
+			obj.vars[name] = zero(dataType)
+		}
+		for i := range obj.states {
+			if err := e.execBlock(q.Statements, obj, i, 0, len(obj.states)-1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e.results, nil
+}
+
+// executor holds the state of a query execution.
+type executor struct {
+	properties map[string]*sky.Property
+	vars       map[string]string
+	results    map[string]interface{}
+}
+
+// object holds the states and variables of the object being executed.
+type object struct {
+	timestamps []time.Time
+	states     []map[string]interface{}
+	vars       map[string]interface{}
+	sessions   map[*SessionLoop][]session
+}
+
+// session holds the index of the first and last events in a session.
+type session struct {
+	lo, hi int
+}
+
+// declare registers all variable declarations in the query.
+func (e *executor) declare(stmts []Statement) error {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *Declaration:
+			if _, ok := e.vars[stmt.Name]; ok {
+				return errorf(stmt.pos, "variable already declared: %s", stmt.Name)
+			} else if _, ok := e.properties[stmt.Name]; ok {
+				return errorf(stmt.pos, "variable conflicts with property: %s", stmt.Name)
+			}
+			e.vars[stmt.Name] = stmt.DataType
+		case *Condition:
+			if err := e.declare(stmt.Statements); err != nil {
+				return err
+			}
+		case *SessionLoop:
+			if err := e.declare(stmt.Statements); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// execBlock executes statements at event i. Events lo through hi are the
+// bounds of the current session.
+func (e *executor) execBlock(stmts []Statement, obj *object, i, lo, hi int) error {
+	for _, stmt := range stmts {
+		var err error
+		switch stmt := stmt.(type) {
+		case *Assignment:
+			err = e.execAssignment(stmt, obj, i, lo, hi)
+		case *Selection:
+			err = e.execSelection(stmt, obj, i, lo, hi)
+		case *Condition:
+			err = e.execCondition(stmt, obj, i, lo, hi)
+		case *SessionLoop:
+			err = e.execSessionLoop(stmt, obj, i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *executor) execAssignment(stmt *Assignment, obj *object, i, lo, hi int) error {
+	dataType, ok := e.vars[stmt.Name]
+	if !ok {
+		return errorf(stmt.pos, "undeclared variable: %s", stmt.Name)
+	}
+	v, err := e.eval(stmt.Expr, obj, i, lo, hi)
+	if err != nil {
+		return err
+	}
+	value, ok := convert(v, dataType)
+	if !ok {
+		return errorf(stmt.Expr.Pos(), "cannot assign %T to %s variable %s", v, dataType, stmt.Name)
+	}
+	obj.vars[stmt.Name] = value
+	return nil
+}
+
+func (e *executor) execSelection(stmt *Selection, obj *object, i, lo, hi int) error {
+	if stmt.Where != nil {
+		if ok, err := e.evalBool(stmt.Where, obj, i, lo, hi); err != nil || !ok {
+			return err
+		}
+	}
+
+	// Find the result map for the selection's group.
+	m := e.results
+	if stmt.Into != "" {
+		var ok bool
+		if m, ok = submap(m, stmt.Into); !ok {
+			return errorf(stmt.pos, "result key conflict: %s", stmt.Into)
+		}
+	}
+	for _, d := range stmt.Dimensions {
+		v, err := e.eval(d, obj, i, lo, hi)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if m, ok = submap(m, d.Name); ok {
+			m, ok = submap(m, formatKey(v))
+		}
+		if !ok {
+			return errorf(d.pos, "result key conflict: %s", d.Name)
+		}
+	}
+
+	// Update each aggregate.
+	for _, f := range stmt.Fields {
+		name := f.Name()
+		if f.Aggregate == "count" {
+			n, _ := m[name].(float64)
+			m[name] = n + 1
+			continue
+		}
+
+		v, err := e.eval(f.Expr, obj, i, lo, hi)
+		if err != nil {
+			return err
+		}
+		x, ok := toFloat(v)
+		if !ok {
+			return errorf(f.Expr.Pos(), "%s() requires a numeric value, found %T", f.Aggregate, v)
+		}
+		prev, exists := m[name].(float64)
+		switch {
+		case !exists:
+			m[name] = x
+		case f.Aggregate == "sum":
+			m[name] = prev + x
+		case f.Aggregate == "min" && x < prev:
+			m[name] = x
+		case f.Aggregate == "max" && x > prev:
+			m[name] = x
+		}
+	}
+	return nil
+}
+
+func (e *executor) execCondition(stmt *Condition, obj *object, i, lo, hi int) error {
+	start, end := i, i
+	if stmt.Within {
+		start, end = i+stmt.Start, i+stmt.End
+	}
+	if end > hi {
+		end = hi
+	}
+	for j := start; j <= end; j++ {
+		ok, err := e.evalBool(stmt.Expr, obj, j, lo, hi)
+		if err != nil {
+			return err
+		} else if ok {
+			return e.execBlock(stmt.Statements, obj, j, lo, hi)
+		}
+	}
+	return nil
+}
+
+func (e *executor) execSessionLoop(stmt *SessionLoop, obj *object, i int) error {
+	// Compute the session bounds of each event once per object.
+	bounds, ok := obj.sessions[stmt]
+	if !ok {
+		bounds = make([]session, len(obj.timestamps))
+		for j := range obj.timestamps {
+			if j > 0 && obj.timestamps[j].Sub(obj.timestamps[j-1]) <= stmt.Idle {
+				bounds[j].lo = bounds[j-1].lo
+			} else {
+				bounds[j].lo = j
+			}
+		}
+		for j := len(bounds) - 1; j >= 0; j-- {
+			if j+1 < len(bounds) && bounds[j+1].lo == bounds[j].lo {
+				bounds[j].hi = bounds[j+1].hi
+			} else {
+				bounds[j].hi = j
+			}
+		}
+		obj.sessions[stmt] = bounds
+	}
+	return e.execBlock(stmt.Statements, obj, i, bounds[i].lo, bounds[i].hi)
+}
+
+// evalBool evaluates an expression that must return a boolean.
+func (e *executor) evalBool(expr Expr, obj *object, i, lo, hi int) (bool, error) {
+	v, err := e.eval(expr, obj, i, lo, hi)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errorf(expr.Pos(), "expected boolean expression, found %T", v)
+	}
+	return b, nil
+}
+
+// eval evaluates an expression at event i. Values are int64, float64, string
+// or bool.
+func (e *executor) eval(expr Expr, obj *object, i, lo, hi int) (interface{}, error) {
+	switch expr := expr.(type) {
+	case *IntegerLiteral:
+		return expr.Value, nil
+	case *FloatLiteral:
+		return expr.Value, nil
+	case *StringLiteral:
+		return expr.Value, nil
+	case *BooleanLiteral:
+		return expr.Value, nil
+
+	case *Ident:
+		if _, ok := e.vars[expr.Name]; ok {
+			return obj.vars[expr.Name], nil
+		}
+		p, ok := e.properties[expr.Name]
+		if !ok {
+			return nil, errorf(expr.pos, "unknown property: %s", expr.Name)
+		}
+		v, ok := convert(obj.states[i][expr.Name], p.DataType)
+		if !ok {
+			return nil, errorf(expr.pos, "invalid %s value for %s: %v", p.DataType, expr.Name, obj.states[i][expr.Name])
+		}
+		return v, nil
+
+	case *Var:
+		switch expr.Name {
+		case "timestamp":
+			return obj.timestamps[i].Unix(), nil
+		case "eos":
+			return i == hi, nil
+		case "eof":
+			return i == len(obj.states)-1, nil
+		}
+		return nil, errorf(expr.pos, "unknown system variable: @%s", expr.Name)
+
+	case *UnaryExpr:
+		x, err := e.eval(expr.X, obj, i, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case bool:
+			if expr.Op == NOT {
+				return !x, nil
+			}
+		case int64:
+			if expr.Op == SUB {
+				return -x, nil
+			}
+		case float64:
+			if expr.Op == SUB {
+				return -x, nil
+			}
+		}
+		return nil, errorf(expr.pos, "invalid operation: %s %T", expr.Op, x)
+
+	case *BinaryExpr:
+		return e.evalBinary(expr, obj, i, lo, hi)
+	}
+	return nil, errorf(expr.Pos(), "invalid expression: %T", expr)
+}
+
+func (e *executor) evalBinary(expr *BinaryExpr, obj *object, i, lo, hi int) (interface{}, error) {
+	// Boolean operators short circuit.
+	if expr.Op == AND || expr.Op == OR {
+		lhs, err := e.evalBool(expr.LHS, obj, i, lo, hi)
+		if err != nil {
+			return nil, err
+		} else if (expr.Op == AND && !lhs) || (expr.Op == OR && lhs) {
+			return lhs, nil
+		}
+		return e.evalBool(expr.RHS, obj, i, lo, hi)
+	}
+
+	lhs, err := e.eval(expr.LHS, obj, i, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := e.eval(expr.RHS, obj, i, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	if (expr.Op == DIV || expr.Op == MOD) && isZero(rhs) {
+		return nil, errorf(expr.pos, "division by zero")
+	}
+	if v, ok := binary(expr.Op, lhs, rhs); ok {
+		return v, nil
+	}
+	return nil, errorf(expr.pos, "invalid operation: %T %s %T", lhs, expr.Op, rhs)
+}
+
+// binary applies an operator to two values. Integer arithmetic is used when
+// both values are integers.
+func binary(op Token, lhs, rhs interface{}) (interface{}, bool) {
+	switch l := lhs.(type) {
+	case string:
+		r, ok := rhs.(string)
+		if !ok {
+			return nil, false
+		}
+		return compare(op, stringCmp(l, r))
+	case bool:
+		r, ok := rhs.(bool)
+		if !ok {
+			return nil, false
+		}
+		switch op {
+		case EQ:
+			return l == r, true
+		case NEQ:
+			return l != r, true
+		}
+		return nil, false
+	}
+
+	// Both values must be numeric.
+	if l, ok := lhs.(int64); ok {
+		if r, ok := rhs.(int64); ok {
+			switch op {
+			case ADD:
+				return l + r, true
+			case SUB:
+				return l - r, true
+			case MUL:
+				return l * r, true
+			case DIV:
+				return l / r, true
+			case MOD:
+				return l % r, true
+			}
+			return compare(op, intCmp(l, r))
+		}
+	}
+	l, lok := toFloat(lhs)
+	r, rok := toFloat(rhs)
+	if !lok || !rok {
+		return nil, false
+	}
+	switch op {
+	case ADD:
+		return l + r, true
+	case SUB:
+		return l - r, true
+	case MUL:
+		return l * r, true
+	case DIV:
+		return l / r, true
+	}
+	return compare(op, floatCmp(l, r))
+}
+
+// isZero returns true if v is a numeric zero.
+func isZero(v interface{}) bool {
+	f, ok := toFloat(v)
+	return ok && f == 0
+}
+
+// compare converts a three-way comparison into the result of an operator.
+func compare(op Token, cmp int) (interface{}, bool) {
+	switch op {
+	case EQ:
+		return cmp == 0, true
+	case NEQ:
+		return cmp != 0, true
+	case LT:
+		return cmp < 0, true
+	case LTE:
+		return cmp <= 0, true
+	case GT:
+		return cmp > 0, true
+	case GTE:
+		return cmp >= 0, true
+	}
+	return nil, false
+}
+
+func stringCmp(l, r string) int {
+	if l < r {
+		return -1
+	} else if l > r {
+		return 1
+	}
+	return 0
+}
+
+func intCmp(l, r int64) int {
+	if l < r {
+		return -1
+	} else if l > r {
+		return 1
+	}
+	return 0
+}
+
+func floatCmp(l, r float64) int {
+	if l < r {
+		return -1
+	} else if l > r {
+		return 1
+	}
+	return 0
+}
+
+// submap returns the map stored under a key, creating it if it does not exist.
+// Returns false if the key holds a non-map value.
+func submap(m map[string]interface{}, key string) (map[string]interface{}, bool) {
+	if v, ok := m[key]; ok {
+		sub, ok := v.(map[string]interface{})
+		return sub, ok
+	}
+	sub := map[string]interface{}{}
+	m[key] = sub
+	return sub, true
+}
+
+// formatKey formats a value as a result key.
+func formatKey(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// zero returns the zero value for a data type.
+func zero(dataType string) interface{} {
+	switch dataType {
+	case sky.Integer:
+		return int64(0)
+	case sky.Float:
+		return float64(0)
+	case sky.Boolean:
+		return false
+	}
+	return ""
+}
+
+// convert converts a value to the representation of a data type. A nil value
+// converts to the zero value.
+func convert(v interface{}, dataType string) (interface{}, bool) {
+	if v == nil {
+		return zero(dataType), true
+	}
+	switch dataType {
+	case sky.Integer:
+		switch v := v.(type) {
+		case int64:
+			return v, true
+		case int:
+			return int64(v), true
+		}
+		if f, ok := toFloat(v); ok {
+			return int64(f), true
+		}
+	case sky.Float:
+		if f, ok := toFloat(v); ok {
+			return f, true
+		}
+	case sky.Boolean:
+		b, ok := v.(bool)
+		return b, ok
+	case sky.String, sky.Factor:
+		switch v.(type) {
+		case string, int64, float64, bool:
+			return formatKey(v), true
+		}
+	}
+	return nil, false
+}
+
+// toFloat converts any numeric value to a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,134 @@
+package skyql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daemonchen/gosky"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProperties = []*sky.Property{
+	{Name: "gender", Transient: false, DataType: sky.Factor},
+	{Name: "action", Transient: true, DataType: sky.Factor},
+	{Name: "price", Transient: true, DataType: sky.Float},
+	{Name: "count", Transient: true, DataType: sky.Integer},
+}
+
+// testEvents returns events for two objects. Object o1 has two sessions
+// separated by an hour.
+func testEvents() []sky.ObjectEvent {
+	t0, _ := sky.ParseTimestamp("1970-01-01T00:00:00Z")
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	return []sky.ObjectEvent{
+		{ID: "o0", Event: &sky.Event{Timestamp: at(0), Data: map[string]interface{}{"gender": "m", "action": "home"}}},
+		{ID: "o0", Event: &sky.Event{Timestamp: at(1 * time.Minute), Data: map[string]interface{}{"action": "cart", "price": 10.0}}},
+		{ID: "o0", Event: &sky.Event{Timestamp: at(2 * time.Minute), Data: map[string]interface{}{"action": "checkout", "price": 20.0}}},
+		{ID: "o1", Event: &sky.Event{Timestamp: at(0), Data: map[string]interface{}{"gender": "f", "action": "home"}}},
+		{ID: "o1", Event: &sky.Event{Timestamp: at(1 * time.Minute), Data: map[string]interface{}{"action": "cart", "price": 5.0, "count": float64(2)}}},
+		{ID: "o1", Event: &sky.Event{Timestamp: at(time.Hour), Data: map[string]interface{}{"action": "checkout", "price": 7.0}}},
+	}
+}
+
+// Ensure that queries produce the same result shape as the server.
+func TestEvaluate(t *testing.T) {
+	var tests = []struct {
+		query  string
+		result map[string]interface{}
+	}{
+		{
+			`SELECT count()`,
+			map[string]interface{}{"count": float64(6)},
+		},
+		{
+			`SELECT count(), sum(price) AS revenue, min(price) AS lo, max(price) AS hi WHERE price > 0`,
+			map[string]interface{}{"count": float64(4), "revenue": float64(42), "lo": float64(5), "hi": float64(20)},
+		},
+		{
+			`SELECT count() GROUP BY gender, action INTO "x"`,
+			map[string]interface{}{"x": map[string]interface{}{"gender": map[string]interface{}{
+				"m": map[string]interface{}{"action": map[string]interface{}{
+					"home":     map[string]interface{}{"count": float64(1)},
+					"cart":     map[string]interface{}{"count": float64(1)},
+					"checkout": map[string]interface{}{"count": float64(1)},
+				}},
+				"f": map[string]interface{}{"action": map[string]interface{}{
+					"home":     map[string]interface{}{"count": float64(1)},
+					"cart":     map[string]interface{}{"count": float64(1)},
+					"checkout": map[string]interface{}{"count": float64(1)},
+				}},
+			}}},
+		},
+		{
+			`SELECT sum(count) AS n GROUP BY count`,
+			map[string]interface{}{"count": map[string]interface{}{
+				"0": map[string]interface{}{"n": float64(0)},
+				"2": map[string]interface{}{"n": float64(2)},
+			}},
+		},
+		{
+			`WHEN action == "home" THEN
+				WHEN action == "checkout" WITHIN 1 .. 2 STEPS THEN
+					SELECT count() AS converted
+				END
+			END`,
+			map[string]interface{}{"converted": float64(2)},
+		},
+		{
+			`FOR EACH SESSION DELIMITED BY 30 MINUTES
+				WHEN action == "home" THEN
+					WHEN action == "checkout" WITHIN 1 .. 2 STEPS THEN
+						SELECT count() AS converted
+					END
+				END
+				WHEN @eos THEN
+					SELECT count() AS sessions
+				END
+			END`,
+			map[string]interface{}{"converted": float64(1), "sessions": float64(3)},
+		},
+		{
+			`DECLARE n AS INTEGER
+			SET n = n + 1
+			WHEN @eof THEN
+				SELECT count() GROUP BY n
+			END`,
+			map[string]interface{}{"n": map[string]interface{}{"3": map[string]interface{}{"count": float64(2)}}},
+		},
+		{
+			`SELECT count() WHERE @timestamp >= 60 && (action == "cart" || action == "checkout")`,
+			map[string]interface{}{"count": float64(4)},
+		},
+	}
+	for _, tt := range tests {
+		result, err := Evaluate(tt.query, testProperties, testEvents())
+		if assert.NoError(t, err, tt.query) {
+			assert.Equal(t, tt.result, result, tt.query)
+		}
+	}
+}
+
+// Ensure that runtime errors report their position.
+func TestEvaluateErrors(t *testing.T) {
+	var tests = []struct {
+		query string
+		err   string
+	}{
+		{`SELECT count() WHERE foo == 1`, `1:22: unknown property: foo`},
+		{`SELECT sum(action)`, `1:12: sum() requires a numeric value, found string`},
+		{`SELECT count() WHERE action == 1`, `1:29: invalid operation: string == int64`},
+		{`WHEN price THEN END`, `1:6: expected boolean expression, found float64`},
+		{`SET x = 1`, `1:1: undeclared variable: x`},
+		{`DECLARE action AS INTEGER`, `1:1: variable conflicts with property: action`},
+		{`SELECT count() WHERE @foo`, `1:22: unknown system variable: @foo`},
+		{`SELECT sum(price / 0)`, `1:18: division by zero`},
+		{`SELECT count() WHERE @timestamp % 0 == 0`, `1:33: division by zero`},
+		{`SELECT sum(price / 0.0)`, `1:18: division by zero`},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.query, testProperties, testEvents())
+		if assert.Error(t, err, tt.query) {
+			assert.Equal(t, err.Error(), tt.err, tt.query)
+		}
+	}
+}
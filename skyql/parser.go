@@ -0,0 +1,446 @@
+package skyql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daemonchen/gosky"
+)
+
+// Error is a syntax or semantic error at a position in a query.
+type Error struct {
+	Pos     Pos
+	Message string
+}
+
+// Error returns the message prefixed with its position.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+func errorf(pos Pos, format string, v ...interface{}) *Error {
+	return &Error{Pos: pos, Message: fmt.Sprintf(format, v...)}
+}
+
+// Parse parses the text of a query.
+func Parse(query string) (*Query, error) {
+	p := &Parser{s: NewScanner(query)}
+	return p.ParseQuery()
+}
+
+// Parser builds a query AST from a scanner.
+type Parser struct {
+	s   *Scanner
+	buf struct {
+		tok Token
+		pos Pos
+		lit string
+		n   int
+	}
+}
+
+// ParseQuery parses statements until the end of the input.
+func (p *Parser) ParseQuery() (*Query, error) {
+	stmts, err := p.parseStatements(EOF)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Statements: stmts}, nil
+}
+
+// parseStatements parses statements until the terminating token, which is
+// consumed.
+func (p *Parser) parseStatements(term Token) ([]Statement, error) {
+	stmts := []Statement{}
+	for {
+		tok, pos, _ := p.scan()
+		switch tok {
+		case term:
+			return stmts, nil
+		case SEMICOLON:
+			continue
+		case EOF:
+			return nil, errorf(pos, "expected %s, found EOF", term)
+		}
+		p.unscan()
+
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+func (p *Parser) parseStatement() (Statement, error) {
+	tok, pos, lit := p.scan()
+	switch tok {
+	case DECLARE:
+		return p.parseDeclaration(pos)
+	case SET:
+		return p.parseAssignment(pos)
+	case SELECT:
+		return p.parseSelection(pos)
+	case WHEN:
+		return p.parseCondition(pos)
+	case FOR:
+		return p.parseSessionLoop(pos)
+	}
+	return nil, errorf(pos, "expected statement, found %s", describe(tok, lit))
+}
+
+// DECLARE ident AS type
+func (p *Parser) parseDeclaration(pos Pos) (*Declaration, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(AS); err != nil {
+		return nil, err
+	}
+	tok, tpos, lit := p.scan()
+	dataType := strings.ToLower(lit)
+	if tok != IDENT || !isDataType(dataType) {
+		return nil, errorf(tpos, "expected data type, found %s", describe(tok, lit))
+	}
+
+	// Factor variables may name the property they share values with.
+	if dataType == sky.Factor {
+		if tok, _, _ := p.scan(); tok == LPAREN {
+			if _, err := p.parseIdent(); err != nil {
+				return nil, err
+			} else if err := p.expect(RPAREN); err != nil {
+				return nil, err
+			}
+		} else {
+			p.unscan()
+		}
+	}
+	return &Declaration{pos: pos, Name: name.Name, DataType: dataType}, nil
+}
+
+// SET ident = expr
+func (p *Parser) parseAssignment(pos Pos) (*Assignment, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(ASSIGN); err != nil {
+		return nil, err
+	}
+	expr, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &Assignment{pos: pos, Name: name.Name, Expr: expr}, nil
+}
+
+// SELECT field, ... [GROUP BY ident, ...] [WHERE expr] [INTO string]
+func (p *Parser) parseSelection(pos Pos) (*Selection, error) {
+	s := &Selection{pos: pos}
+	for {
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		s.Fields = append(s.Fields, f)
+		if tok, _, _ := p.scan(); tok != COMMA {
+			p.unscan()
+			break
+		}
+	}
+
+	if tok, _, _ := p.scan(); tok == GROUP {
+		if err := p.expect(BY); err != nil {
+			return nil, err
+		}
+		for {
+			ident, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			s.Dimensions = append(s.Dimensions, ident)
+			if tok, _, _ := p.scan(); tok != COMMA {
+				p.unscan()
+				break
+			}
+		}
+	} else {
+		p.unscan()
+	}
+
+	if tok, _, _ := p.scan(); tok == WHERE {
+		expr, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		s.Where = expr
+	} else {
+		p.unscan()
+	}
+
+	if tok, _, _ := p.scan(); tok == INTO {
+		tok, pos, lit := p.scan()
+		if tok != STRING {
+			return nil, errorf(pos, "expected string, found %s", describe(tok, lit))
+		}
+		s.Into = lit
+	} else {
+		p.unscan()
+	}
+	return s, nil
+}
+
+// aggregate([expr]) [AS ident]
+func (p *Parser) parseField() (*Field, error) {
+	tok, pos, lit := p.scan()
+	name := strings.ToLower(lit)
+	if tok != IDENT || !isAggregate(name) {
+		return nil, errorf(pos, "expected aggregate function, found %s", describe(tok, lit))
+	}
+	f := &Field{pos: pos, Aggregate: name}
+	if err := p.expect(LPAREN); err != nil {
+		return nil, err
+	}
+	if tok, _, _ := p.scan(); tok != RPAREN {
+		p.unscan()
+		expr, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		f.Expr = expr
+		if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+	}
+	if f.Expr == nil && f.Aggregate != "count" {
+		return nil, errorf(pos, "%s() requires an argument", f.Aggregate)
+	}
+
+	if tok, _, _ := p.scan(); tok == AS {
+		alias, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		f.Alias = alias.Name
+	} else {
+		p.unscan()
+	}
+	return f, nil
+}
+
+// WHEN expr [WITHIN int .. int STEPS] THEN statements END
+func (p *Parser) parseCondition(pos Pos) (*Condition, error) {
+	expr, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	c := &Condition{pos: pos, Expr: expr}
+
+	if tok, _, _ := p.scan(); tok == WITHIN {
+		c.Within = true
+		if c.Start, err = p.parseInt(); err != nil {
+			return nil, err
+		} else if err := p.expect(RANGE); err != nil {
+			return nil, err
+		} else if c.End, err = p.parseInt(); err != nil {
+			return nil, err
+		} else if err := p.expect(STEPS); err != nil {
+			return nil, err
+		}
+		if c.End < c.Start {
+			return nil, errorf(pos, "invalid range: %d .. %d", c.Start, c.End)
+		}
+	} else {
+		p.unscan()
+	}
+
+	if err := p.expect(THEN); err != nil {
+		return nil, err
+	}
+	if c.Statements, err = p.parseStatements(END); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FOR EACH SESSION DELIMITED BY int unit statements END
+func (p *Parser) parseSessionLoop(pos Pos) (*SessionLoop, error) {
+	for _, tok := range []Token{EACH, SESSION, DELIMITED, BY} {
+		if err := p.expect(tok); err != nil {
+			return nil, err
+		}
+	}
+	n, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+	tok, upos, lit := p.scan()
+	unit, ok := units[strings.ToUpper(lit)]
+	if tok != IDENT || !ok {
+		return nil, errorf(upos, "expected time unit, found %s", describe(tok, lit))
+	}
+	l := &SessionLoop{pos: pos, Idle: time.Duration(n) * unit}
+	if l.Statements, err = p.parseStatements(END); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ParseExpr parses a binary expression.
+func (p *Parser) ParseExpr() (Expr, error) {
+	return p.parseBinaryExpr(1)
+}
+
+// parseBinaryExpr parses operators with at least the given precedence.
+func (p *Parser) parseBinaryExpr(prec int) (Expr, error) {
+	lhs, err := p.parseUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, pos, _ := p.scan()
+		if tok.Precedence() < prec {
+			p.unscan()
+			return lhs, nil
+		}
+		rhs, err := p.parseBinaryExpr(tok.Precedence() + 1)
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{pos: pos, Op: tok, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *Parser) parseUnaryExpr() (Expr, error) {
+	tok, pos, lit := p.scan()
+	switch tok {
+	case NOT, SUB:
+		x, err := p.parseUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{pos: pos, Op: tok, X: x}, nil
+	case LPAREN:
+		expr, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		} else if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case IDENT:
+		return &Ident{pos: pos, Name: lit}, nil
+	case VAR:
+		return &Var{pos: pos, Name: lit}, nil
+	case STRING:
+		return &StringLiteral{pos: pos, Value: lit}, nil
+	case TRUE, FALSE:
+		return &BooleanLiteral{pos: pos, Value: tok == TRUE}, nil
+	case INT:
+		v, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil {
+			return nil, errorf(pos, "invalid integer: %s", lit)
+		}
+		return &IntegerLiteral{pos: pos, Value: v}, nil
+	case FLOAT:
+		v, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, errorf(pos, "invalid float: %s", lit)
+		}
+		return &FloatLiteral{pos: pos, Value: v}, nil
+	}
+	if tok.isKeyword() {
+		return &Ident{pos: pos, Name: lit}, nil
+	}
+	return nil, errorf(pos, "expected expression, found %s", describe(tok, lit))
+}
+
+// parseIdent parses an identifier. Keywords are accepted as identifiers so
+// that properties such as "end" or "session" can be used.
+func (p *Parser) parseIdent() (*Ident, error) {
+	tok, pos, lit := p.scan()
+	if tok != IDENT && !tok.isKeyword() {
+		return nil, errorf(pos, "expected identifier, found %s", describe(tok, lit))
+	}
+	return &Ident{pos: pos, Name: lit}, nil
+}
+
+func (p *Parser) parseInt() (int, error) {
+	tok, pos, lit := p.scan()
+	if tok != INT {
+		return 0, errorf(pos, "expected integer, found %s", describe(tok, lit))
+	}
+	n, err := strconv.Atoi(lit)
+	if err != nil {
+		return 0, errorf(pos, "invalid integer: %s", lit)
+	}
+	return n, nil
+}
+
+// expect consumes the next token and returns an error if it is not tok.
+func (p *Parser) expect(tok Token) error {
+	if t, pos, lit := p.scan(); t != tok {
+		return errorf(pos, "expected %s, found %s", tok, describe(t, lit))
+	}
+	return nil
+}
+
+// scan returns the next token, or the buffered token after an unscan.
+func (p *Parser) scan() (Token, Pos, string) {
+	if p.buf.n != 0 {
+		p.buf.n = 0
+		return p.buf.tok, p.buf.pos, p.buf.lit
+	}
+	p.buf.tok, p.buf.pos, p.buf.lit = p.s.Scan()
+	return p.buf.tok, p.buf.pos, p.buf.lit
+}
+
+// unscan pushes the last token back so it is returned by the next scan.
+func (p *Parser) unscan() { p.buf.n = 1 }
+
+// describe returns a description of a token for error messages.
+func describe(tok Token, lit string) string {
+	switch tok {
+	case IDENT, INT, FLOAT:
+		return fmt.Sprintf("%q", lit)
+	case VAR:
+		return fmt.Sprintf("%q", "@"+lit)
+	case STRING:
+		return fmt.Sprintf("string %q", lit)
+	case ILLEGAL:
+		return fmt.Sprintf("illegal token %q", lit)
+	case EOF:
+		return "EOF"
+	}
+	return tok.String()
+}
+
+var units = map[string]time.Duration{
+	"SECOND":  time.Second,
+	"SECONDS": time.Second,
+	"MINUTE":  time.Minute,
+	"MINUTES": time.Minute,
+	"HOUR":    time.Hour,
+	"HOURS":   time.Hour,
+	"DAY":     24 * time.Hour,
+	"DAYS":    24 * time.Hour,
+}
+
+func isAggregate(name string) bool {
+	switch name {
+	case "count", "sum", "min", "max":
+		return true
+	}
+	return false
+}
+
+func isDataType(name string) bool {
+	switch name {
+	case sky.String, sky.Integer, sky.Float, sky.Boolean, sky.Factor:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package skyql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that a query with every statement type can be parsed.
+func TestParse(t *testing.T) {
+	q, err := Parse(`
+		DECLARE n AS INTEGER
+		FOR EACH SESSION DELIMITED BY 30 MINUTES
+			SET n = n + 1
+			WHEN action == "A" && price > 10.5 WITHIN 1 .. 2 STEPS THEN
+				SELECT count(), sum(price) AS revenue GROUP BY action, gender WHERE !(n == 0) INTO 'x';
+			END
+		END
+	`)
+	if assert.NoError(t, err) && assert.Equal(t, len(q.Statements), 2) {
+		decl := q.Statements[0].(*Declaration)
+		assert.Equal(t, decl.Name, "n")
+		assert.Equal(t, decl.DataType, "integer")
+		assert.Equal(t, decl.Pos(), Pos{2, 3})
+
+		loop := q.Statements[1].(*SessionLoop)
+		assert.Equal(t, loop.Idle, 30*time.Minute)
+		assert.Equal(t, len(loop.Statements), 2)
+
+		cond := loop.Statements[1].(*Condition)
+		assert.True(t, cond.Within)
+		assert.Equal(t, cond.Start, 1)
+		assert.Equal(t, cond.End, 2)
+		assert.Equal(t, cond.Expr.(*BinaryExpr).Op, AND)
+
+		sel := cond.Statements[0].(*Selection)
+		assert.Equal(t, len(sel.Fields), 2)
+		assert.Equal(t, sel.Fields[0].Name(), "count")
+		assert.Equal(t, sel.Fields[1].Name(), "revenue")
+		assert.Equal(t, sel.Dimensions[1].Name, "gender")
+		assert.Equal(t, sel.Where.(*UnaryExpr).Op, NOT)
+		assert.Equal(t, sel.Into, "x")
+	}
+}
+
+// Ensure that arithmetic binds tighter than comparison and boolean operators.
+func TestParsePrecedence(t *testing.T) {
+	q, err := Parse(`WHEN a + b * 2 > 3 || c THEN END`)
+	if assert.NoError(t, err) {
+		or := q.Statements[0].(*Condition).Expr.(*BinaryExpr)
+		assert.Equal(t, or.Op, OR)
+		gt := or.LHS.(*BinaryExpr)
+		assert.Equal(t, gt.Op, GT)
+		add := gt.LHS.(*BinaryExpr)
+		assert.Equal(t, add.Op, ADD)
+		assert.Equal(t, add.RHS.(*BinaryExpr).Op, MUL)
+	}
+}
+
+// Ensure that keywords can be used as identifiers in any case.
+func TestParseKeywordIdents(t *testing.T) {
+	q, err := Parse(`
+		DECLARE set AS INTEGER
+		SET set = End + 1
+		WHEN session == "a" && STEPS > 1 WITHIN 1 .. 2 STEPS THEN
+			SELECT sum(end) AS within GROUP BY session, group
+		END
+	`)
+	if assert.NoError(t, err) && assert.Equal(t, len(q.Statements), 3) {
+		assert.Equal(t, q.Statements[0].(*Declaration).Name, "set")
+		assign := q.Statements[1].(*Assignment)
+		assert.Equal(t, assign.Name, "set")
+		assert.Equal(t, assign.Expr.(*BinaryExpr).LHS.(*Ident).Name, "End")
+
+		cond := q.Statements[2].(*Condition)
+		and := cond.Expr.(*BinaryExpr)
+		assert.Equal(t, and.LHS.(*BinaryExpr).LHS.(*Ident).Name, "session")
+		assert.Equal(t, and.RHS.(*BinaryExpr).LHS.(*Ident).Name, "STEPS")
+		sel := cond.Statements[0].(*Selection)
+		assert.Equal(t, sel.Fields[0].Expr.(*Ident).Name, "end")
+		assert.Equal(t, sel.Fields[0].Name(), "within")
+		assert.Equal(t, sel.Dimensions[0].Name, "session")
+		assert.Equal(t, sel.Dimensions[1].Name, "group")
+	}
+}
+
+// Ensure that syntax errors report their position.
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		query string
+		err   string
+	}{
+		{`SELECT`, `1:7: expected aggregate function, found EOF`},
+		{`SELECT avg(x)`, `1:8: expected aggregate function, found "avg"`},
+		{`SELECT sum()`, `1:8: sum() requires an argument`},
+		{"SELECT count()\nGROUP action", `2:7: expected BY, found "action"`},
+		{`WHEN x == 1 THEN SELECT count()`, `1:32: expected END, found EOF`},
+		{`WHEN x == "foo THEN END`, `1:11: expected expression, found illegal token "unterminated string"`},
+		{`WHEN x WITHIN 2 .. 1 STEPS THEN END`, `1:1: invalid range: 2 .. 1`},
+		{`FOR EACH SESSION DELIMITED BY 2 WEEKS END`, `1:33: expected time unit, found "WEEKS"`},
+		{`DECLARE x AS DATE`, `1:14: expected data type, found "DATE"`},
+		{`DECLARE true AS INTEGER`, `1:9: expected identifier, found TRUE`},
+		{`END`, `1:1: expected statement, found END`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if assert.Error(t, err, tt.query) {
+			assert.Equal(t, err.Error(), tt.err, tt.query)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package skyql
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scanner breaks a query into tokens.
+type Scanner struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+// NewScanner returns a scanner for the query text.
+func NewScanner(src string) *Scanner {
+	return &Scanner{src: src, line: 1, col: 1}
+}
+
+// Scan returns the next token, its position and its literal value.
+func (s *Scanner) Scan() (tok Token, pos Pos, lit string) {
+	s.skipWhitespace()
+	pos = Pos{Line: s.line, Column: s.col}
+
+	ch := s.peek()
+	switch {
+	case ch == eof:
+		return EOF, pos, ""
+	case isLetter(ch):
+		lit = s.scanIdent()
+		return Lookup(lit), pos, lit
+	case ch == '@':
+		s.read()
+		if !isLetter(s.peek()) {
+			return ILLEGAL, pos, "@"
+		}
+		return VAR, pos, s.scanIdent()
+	case isDigit(ch):
+		tok, lit = s.scanNumber()
+		return tok, pos, lit
+	case ch == '"' || ch == '\'':
+		return s.scanString(pos)
+	}
+
+	s.read()
+	switch ch {
+	case '+':
+		return ADD, pos, ""
+	case '-':
+		return SUB, pos, ""
+	case '*':
+		return MUL, pos, ""
+	case '/':
+		return DIV, pos, ""
+	case '%':
+		return MOD, pos, ""
+	case '(':
+		return LPAREN, pos, ""
+	case ')':
+		return RPAREN, pos, ""
+	case ',':
+		return COMMA, pos, ""
+	case ';':
+		return SEMICOLON, pos, ""
+	case '=':
+		if s.accept('=') {
+			return EQ, pos, ""
+		}
+		return ASSIGN, pos, ""
+	case '!':
+		if s.accept('=') {
+			return NEQ, pos, ""
+		}
+		return NOT, pos, ""
+	case '<':
+		if s.accept('=') {
+			return LTE, pos, ""
+		}
+		return LT, pos, ""
+	case '>':
+		if s.accept('=') {
+			return GTE, pos, ""
+		}
+		return GT, pos, ""
+	case '&':
+		if s.accept('&') {
+			return AND, pos, ""
+		}
+	case '|':
+		if s.accept('|') {
+			return OR, pos, ""
+		}
+	case '.':
+		if s.accept('.') {
+			return RANGE, pos, ""
+		}
+	}
+	return ILLEGAL, pos, string(ch)
+}
+
+// skipWhitespace consumes whitespace and "--" line comments.
+func (s *Scanner) skipWhitespace() {
+	for {
+		ch := s.peek()
+		if unicode.IsSpace(ch) {
+			s.read()
+		} else if ch == '-' && s.off+1 < len(s.src) && s.src[s.off+1] == '-' {
+			for ch != eof && ch != '\n' {
+				ch = s.read()
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (s *Scanner) scanIdent() string {
+	start := s.off
+	for ch := s.peek(); isLetter(ch) || isDigit(ch); ch = s.peek() {
+		s.read()
+	}
+	return s.src[start:s.off]
+}
+
+// scanNumber scans an integer or float. A "." followed by another "." is a
+// range operator and ends the number.
+func (s *Scanner) scanNumber() (Token, string) {
+	start := s.off
+	for isDigit(s.peek()) {
+		s.read()
+	}
+	if s.peek() != '.' || (s.off+1 < len(s.src) && s.src[s.off+1] == '.') {
+		return INT, s.src[start:s.off]
+	}
+	s.read()
+	for isDigit(s.peek()) {
+		s.read()
+	}
+	return FLOAT, s.src[start:s.off]
+}
+
+// scanString scans a single or double quoted string with backslash escapes.
+func (s *Scanner) scanString(pos Pos) (Token, Pos, string) {
+	quote := s.read()
+	var buf bytes.Buffer
+	for {
+		ch := s.read()
+		switch ch {
+		case quote:
+			return STRING, pos, buf.String()
+		case eof, '\n':
+			return ILLEGAL, pos, "unterminated string"
+		case '\\':
+			switch esc := s.read(); esc {
+			case 'n':
+				buf.WriteRune('\n')
+			case 't':
+				buf.WriteRune('\t')
+			case eof:
+				return ILLEGAL, pos, "unterminated string"
+			default:
+				buf.WriteRune(esc)
+			}
+		default:
+			buf.WriteRune(ch)
+		}
+	}
+}
+
+const eof = rune(-1)
+
+func (s *Scanner) peek() rune {
+	if s.off >= len(s.src) {
+		return eof
+	}
+	ch, _ := utf8.DecodeRuneInString(s.src[s.off:])
+	return ch
+}
+
+func (s *Scanner) read() rune {
+	if s.off >= len(s.src) {
+		return eof
+	}
+	ch, size := utf8.DecodeRuneInString(s.src[s.off:])
+	s.off += size
+	if ch == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	return ch
+}
+
+func (s *Scanner) accept(ch rune) bool {
+	if s.peek() == ch {
+		s.read()
+		return true
+	}
+	return false
+}
+
+func isLetter(ch rune) bool { return ch == '_' || unicode.IsLetter(ch) }
+func isDigit(ch rune) bool  { return '0' <= ch && ch <= '9' }
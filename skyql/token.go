@@ -0,0 +1,184 @@
+package skyql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Token is a lexical token of the SkyQL language.
+type Token int
+
+const (
+	ILLEGAL Token = iota
+	EOF
+
+	literalBeg
+	IDENT  // action
+	VAR    // @timestamp
+	INT    // 10
+	FLOAT  // 10.5
+	STRING // "foo"
+	literalEnd
+
+	operatorBeg
+	ADD    // +
+	SUB    // -
+	MUL    // *
+	DIV    // /
+	MOD    // %
+	EQ     // ==
+	NEQ    // !=
+	LT     // <
+	LTE    // <=
+	GT     // >
+	GTE    // >=
+	AND    // && or AND
+	OR     // || or OR
+	NOT    // ! or NOT
+	ASSIGN // =
+	operatorEnd
+
+	LPAREN    // (
+	RPAREN    // )
+	COMMA     // ,
+	SEMICOLON // ;
+	RANGE     // ..
+
+	keywordBeg
+	AS
+	BY
+	DECLARE
+	DELIMITED
+	EACH
+	END
+	FALSE
+	FOR
+	GROUP
+	INTO
+	SELECT
+	SESSION
+	SET
+	STEPS
+	THEN
+	TRUE
+	WHEN
+	WHERE
+	WITHIN
+	keywordEnd
+)
+
+var tokens = map[Token]string{
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+
+	IDENT:  "IDENT",
+	VAR:    "VAR",
+	INT:    "INT",
+	FLOAT:  "FLOAT",
+	STRING: "STRING",
+
+	ADD:    "+",
+	SUB:    "-",
+	MUL:    "*",
+	DIV:    "/",
+	MOD:    "%",
+	EQ:     "==",
+	NEQ:    "!=",
+	LT:     "<",
+	LTE:    "<=",
+	GT:     ">",
+	GTE:    ">=",
+	AND:    "&&",
+	OR:     "||",
+	NOT:    "!",
+	ASSIGN: "=",
+
+	LPAREN:    "(",
+	RPAREN:    ")",
+	COMMA:     ",",
+	SEMICOLON: ";",
+	RANGE:     "..",
+
+	AS:        "AS",
+	BY:        "BY",
+	DECLARE:   "DECLARE",
+	DELIMITED: "DELIMITED",
+	EACH:      "EACH",
+	END:       "END",
+	FALSE:     "FALSE",
+	FOR:       "FOR",
+	GROUP:     "GROUP",
+	INTO:      "INTO",
+	SELECT:    "SELECT",
+	SESSION:   "SESSION",
+	SET:       "SET",
+	STEPS:     "STEPS",
+	THEN:      "THEN",
+	TRUE:      "TRUE",
+	WHEN:      "WHEN",
+	WHERE:     "WHERE",
+	WITHIN:    "WITHIN",
+}
+
+var keywords map[string]Token
+
+func init() {
+	keywords = make(map[string]Token)
+	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
+		keywords[tokens[tok]] = tok
+	}
+	keywords["AND"] = AND
+	keywords["OR"] = OR
+	keywords["NOT"] = NOT
+}
+
+// String returns the string representation of the token.
+func (tok Token) String() string {
+	if s, ok := tokens[tok]; ok {
+		return s
+	}
+	return fmt.Sprintf("Token(%d)", int(tok))
+}
+
+// Precedence returns the binding power of a binary operator.
+func (tok Token) Precedence() int {
+	switch tok {
+	case OR:
+		return 1
+	case AND:
+		return 2
+	case EQ, NEQ, LT, LTE, GT, GTE:
+		return 3
+	case ADD, SUB:
+		return 4
+	case MUL, DIV, MOD:
+		return 5
+	}
+	return 0
+}
+
+// Lookup returns the keyword token for an identifier or IDENT if the
+// identifier is not a keyword. Keywords are case insensitive.
+func Lookup(ident string) Token {
+	if tok, ok := keywords[strings.ToUpper(ident)]; ok {
+		return tok
+	}
+	return IDENT
+}
+
+// isKeyword returns true if the token is a keyword that can also be used as an
+// identifier. The boolean literals are not identifiers.
+func (tok Token) isKeyword() bool {
+	return tok > keywordBeg && tok < keywordEnd && tok != TRUE && tok != FALSE
+}
+
+// Pos is a line and column position in a query. Both are 1-based.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// String returns the position as "line:column".
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
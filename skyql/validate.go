@@ -0,0 +1,285 @@
+package skyql
+
+import (
+	"fmt"
+
+	"github.com/daemonchen/gosky"
+)
+
+// ErrorList is a list of errors found while validating a query.
+type ErrorList []*Error
+
+// Error returns the first error and the number of remaining errors.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Validate checks a query for syntax errors and for errors against a table's
+// properties and factor values. It has the signature of
+// sky.Client.QueryValidator so it can be used to check queries before they
+// are sent to the server.
+func Validate(query string, properties []*sky.Property, factorValues map[string][]string) error {
+	v := &Validator{Properties: properties, FactorValues: factorValues}
+	return v.Validate(query)
+}
+
+// Validator checks queries against a table's properties. Besides syntax
+// errors it reports unknown properties and variables, type mismatches,
+// aggregates of non-numeric values and comparisons of a factor against a
+// value that has never been seen for it. A Validator is safe for concurrent
+// use.
+type Validator struct {
+	Properties []*sky.Property
+
+	// FactorValues are the known values of factor properties, as returned
+	// by sky.Table.FactorValues. Factor comparisons are only checked for the
+	// properties in the map.
+	FactorValues map[string][]string
+}
+
+// validation is the state of a single query's validation.
+type validation struct {
+	*Validator
+	properties map[string]*sky.Property
+	vars       map[string]string
+	errors     ErrorList
+}
+
+// Validate returns an ErrorList with all errors in the query or nil if the
+// query is valid.
+func (v *Validator) Validate(query string) error {
+	q, err := Parse(query)
+	if err != nil {
+		if err, ok := err.(*Error); ok {
+			return ErrorList{err}
+		}
+		return err
+	}
+	return v.ValidateQuery(q)
+}
+
+// ValidateQuery returns an ErrorList with all errors in a parsed query or nil
+// if the query is valid.
+func (v *Validator) ValidateQuery(q *Query) error {
+	run := &validation{
+		Validator:  v,
+		properties: make(map[string]*sky.Property),
+		vars:       make(map[string]string),
+	}
+	for _, p := range v.Properties {
+		run.properties[p.Name] = p
+	}
+
+	run.declare(q.Statements)
+	run.checkStatements(q.Statements)
+	if len(run.errors) > 0 {
+		return run.errors
+	}
+	return nil
+}
+
+func (v *validation) errorf(pos Pos, format string, args ...interface{}) {
+	v.errors = append(v.errors, errorf(pos, format, args...))
+}
+
+// declare registers all variable declarations in the query.
+func (v *validation) declare(stmts []Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *Declaration:
+			if _, ok := v.vars[stmt.Name]; ok {
+				v.errorf(stmt.pos, "variable already declared: %s", stmt.Name)
+			} else if _, ok := v.properties[stmt.Name]; ok {
+				v.errorf(stmt.pos, "variable conflicts with property: %s", stmt.Name)
+			} else {
+				v.vars[stmt.Name] = stmt.DataType
+			}
+		case *Condition:
+			v.declare(stmt.Statements)
+		case *SessionLoop:
+			v.declare(stmt.Statements)
+		}
+	}
+}
+
+func (v *validation) checkStatements(stmts []Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *Assignment:
+			dataType, ok := v.vars[stmt.Name]
+			if !ok {
+				v.errorf(stmt.pos, "undeclared variable: %s", stmt.Name)
+			}
+			if t := v.typeOf(stmt.Expr); ok && t != "" && !assignable(dataType, t) {
+				v.errorf(stmt.Expr.Pos(), "cannot assign %s to %s variable %s", t, dataType, stmt.Name)
+			}
+
+		case *Selection:
+			for _, f := range stmt.Fields {
+				if f.Expr == nil {
+					continue
+				}
+				if t := v.typeOf(f.Expr); t != "" && !numeric(t) {
+					v.errorf(f.Expr.Pos(), "%s() requires a numeric value, found %s", f.Aggregate, t)
+				}
+			}
+			for _, d := range stmt.Dimensions {
+				v.typeOf(d)
+			}
+			if stmt.Where != nil {
+				v.checkBool(stmt.Where)
+			}
+
+		case *Condition:
+			v.checkBool(stmt.Expr)
+			v.checkStatements(stmt.Statements)
+
+		case *SessionLoop:
+			if stmt.Idle <= 0 {
+				v.errorf(stmt.pos, "session idle time must be positive")
+			}
+			v.checkStatements(stmt.Statements)
+		}
+	}
+}
+
+// checkBool reports an error if an expression is not boolean.
+func (v *validation) checkBool(expr Expr) {
+	if t := v.typeOf(expr); t != "" && t != sky.Boolean {
+		v.errorf(expr.Pos(), "expected boolean expression, found %s", t)
+	}
+}
+
+// typeOf returns the data type of an expression and reports any errors in it.
+// A blank type is returned if the type cannot be determined because of an
+// earlier error.
+func (v *validation) typeOf(expr Expr) string {
+	switch expr := expr.(type) {
+	case *IntegerLiteral:
+		return sky.Integer
+	case *FloatLiteral:
+		return sky.Float
+	case *StringLiteral:
+		return sky.String
+	case *BooleanLiteral:
+		return sky.Boolean
+
+	case *Ident:
+		if t, ok := v.vars[expr.Name]; ok {
+			return t
+		} else if p, ok := v.properties[expr.Name]; ok {
+			return p.DataType
+		}
+		v.errorf(expr.pos, "unknown property: %s", expr.Name)
+		return ""
+
+	case *Var:
+		switch expr.Name {
+		case "timestamp":
+			return sky.Integer
+		case "eos", "eof":
+			return sky.Boolean
+		}
+		v.errorf(expr.pos, "unknown system variable: @%s", expr.Name)
+		return ""
+
+	case *UnaryExpr:
+		t := v.typeOf(expr.X)
+		if t == "" {
+			return ""
+		} else if expr.Op == NOT && t == sky.Boolean {
+			return t
+		} else if expr.Op == SUB && numeric(t) {
+			return t
+		}
+		v.errorf(expr.pos, "invalid operation: %s %s", expr.Op, t)
+		return ""
+
+	case *BinaryExpr:
+		return v.typeOfBinary(expr)
+	}
+	return ""
+}
+
+func (v *validation) typeOfBinary(expr *BinaryExpr) string {
+	lhs, rhs := v.typeOf(expr.LHS), v.typeOf(expr.RHS)
+	if lhs == "" || rhs == "" {
+		return ""
+	}
+
+	switch expr.Op {
+	case AND, OR:
+		if lhs == sky.Boolean && rhs == sky.Boolean {
+			return sky.Boolean
+		}
+	case ADD, SUB, MUL, DIV, MOD:
+		if numeric(lhs) && numeric(rhs) {
+			if lhs == sky.Integer && rhs == sky.Integer {
+				return sky.Integer
+			}
+			return sky.Float
+		}
+	case EQ, NEQ, LT, LTE, GT, GTE:
+		switch {
+		case numeric(lhs) && numeric(rhs):
+			return sky.Boolean
+		case textual(lhs) && textual(rhs):
+			v.checkFactorValue(expr.LHS, expr.RHS)
+			v.checkFactorValue(expr.RHS, expr.LHS)
+			return sky.Boolean
+		case lhs == sky.Boolean && rhs == sky.Boolean && (expr.Op == EQ || expr.Op == NEQ):
+			return sky.Boolean
+		}
+	}
+	v.errorf(expr.pos, "invalid operation: %s %s %s", lhs, expr.Op, rhs)
+	return ""
+}
+
+// checkFactorValue reports an error if a factor property is compared to a
+// string that is not one of its known values.
+func (v *validation) checkFactorValue(lhs, rhs Expr) {
+	ident, ok := lhs.(*Ident)
+	if !ok {
+		return
+	}
+	lit, ok := rhs.(*StringLiteral)
+	if !ok {
+		return
+	}
+	values, ok := v.FactorValues[ident.Name]
+	if !ok {
+		return
+	}
+	for _, value := range values {
+		if value == lit.Value {
+			return
+		}
+	}
+	v.errorf(lit.pos, "unknown value for factor %s: %q", ident.Name, lit.Value)
+}
+
+func numeric(t string) bool {
+	return t == sky.Integer || t == sky.Float
+}
+
+func textual(t string) bool {
+	return t == sky.String || t == sky.Factor
+}
+
+// assignable returns true if a value of type t can be assigned to a variable
+// of the given data type.
+func assignable(dataType string, t string) bool {
+	switch {
+	case numeric(dataType):
+		return numeric(t)
+	case textual(dataType):
+		return t != sky.Boolean
+	}
+	return dataType == t
+}
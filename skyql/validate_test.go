@@ -0,0 +1,82 @@
+package skyql
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that valid queries pass validation.
+func TestValidate(t *testing.T) {
+	err := Validate(`
+		DECLARE n AS INTEGER
+		SET n = n + count
+		WHEN action == "cart" && @timestamp > 0 THEN
+			SELECT count(), sum(price * 2) AS revenue GROUP BY gender, n
+		END
+	`, testProperties, nil)
+	assert.NoError(t, err)
+}
+
+// Ensure that all errors in a query are reported with their positions.
+func TestValidateErrors(t *testing.T) {
+	var tests = []struct {
+		query  string
+		errors []string
+	}{
+		{`SELECT count(`, []string{`1:14: expected expression, found EOF`}},
+		{`SELECT sum(action), max(foo)`, []string{
+			`1:12: sum() requires a numeric value, found factor`,
+			`1:25: unknown property: foo`,
+		}},
+		{"WHEN price THEN\n  SELECT count() WHERE gender == 1\nEND", []string{
+			`1:6: expected boolean expression, found float`,
+			`2:31: invalid operation: factor == integer`,
+		}},
+		{`DECLARE x AS BOOLEAN SET x = 1 SET y = 2`, []string{
+			`1:30: cannot assign integer to boolean variable x`,
+			`1:32: undeclared variable: y`,
+		}},
+		{`WHEN !price THEN END`, []string{`1:6: invalid operation: ! float`}},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.query, testProperties, nil)
+		if errs, ok := err.(ErrorList); assert.True(t, ok, tt.query) {
+			messages := []string{}
+			for _, e := range errs {
+				messages = append(messages, e.Error())
+			}
+			assert.Equal(t, tt.errors, messages, tt.query)
+		}
+	}
+}
+
+// Ensure that factor comparisons are checked against known values.
+func TestValidatorFactorValues(t *testing.T) {
+	v := &Validator{
+		Properties:   testProperties,
+		FactorValues: map[string][]string{"action": {"home", "cart"}},
+	}
+	assert.NoError(t, v.Validate(`SELECT count() WHERE action == "cart" || gender == "x"`))
+	err := v.Validate(`SELECT count() WHERE "checkout" != action`)
+	assert.Equal(t, err.Error(), `1:22: unknown value for factor action: "checkout"`)
+}
+
+// Ensure that a validator can be shared between goroutines.
+func TestValidatorConcurrent(t *testing.T) {
+	v := &Validator{Properties: testProperties}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				assert.NoError(t, v.Validate(`DECLARE x AS INTEGER SELECT count()`))
+			} else {
+				assert.Error(t, v.Validate(`SELECT count() WHERE foo == 1`))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
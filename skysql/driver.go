@@ -0,0 +1,340 @@
+package skysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/daemonchen/gosky"
+	"github.com/daemonchen/gosky/skyql"
+)
+
+// DriverName is the name the driver is registered under.
+const DriverName = "sky"
+
+var (
+	// ErrTableRequired is returned when a DSN does not name a table.
+	ErrTableRequired = errors.New("skysql: table required in dsn")
+
+	// ErrArgsNotSupported is returned when a query is passed arguments.
+	ErrArgsNotSupported = errors.New("skysql: query arguments not supported")
+
+	// ErrNotSupported is returned for statements and transactions.
+	ErrNotSupported = errors.New("skysql: not supported")
+
+	// ErrColumnConflict is returned when a query result uses the same name
+	// for a dimension and an aggregate.
+	ErrColumnConflict = errors.New("skysql: column is both a dimension and an aggregate")
+)
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver runs SkyQL queries through database/sql. The DSN is the server's
+// host and the table name, such as "localhost:8585/users" or
+// "sky://localhost:8585/users".
+type Driver struct{}
+
+// Open returns a connection to the table named by the DSN.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	host, table, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &sky.Client{Host: host}
+	return &conn{table: &sky.Table{Client: c, Name: table}}, nil
+}
+
+// ParseDSN returns the host and table name from a DSN. The host defaults to
+// sky.DefaultHost.
+func ParseDSN(dsn string) (host, table string, err error) {
+	if !strings.Contains(dsn, "://") {
+		dsn = "sky://" + dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+	host, table = u.Host, strings.Trim(u.Path, "/")
+	if host == "" {
+		host = sky.DefaultHost
+	}
+	if table == "" {
+		return "", "", ErrTableRequired
+	}
+	return host, table, nil
+}
+
+// conn is a connection to a single table. Each query is a separate request so
+// the connection holds no server state.
+type conn struct {
+	table *sky.Table
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return nil, ErrNotSupported }
+
+// Ping checks that the server is reachable. The context's error is returned
+// if it is done before the server responds.
+func (c *conn) Ping(ctx context.Context) error {
+	if !c.table.Client.PingContext(ctx) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// QueryContext runs a SkyQL query and returns its results as rows.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, ErrArgsNotSupported
+	}
+	output, err := c.table.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	columns, values, err := Flatten(output, intoNames(query)...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: columns, values: values}, nil
+}
+
+// stmt is a prepared query. Queries are not sent until they are executed.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return 0 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, ErrArgsNotSupported
+	}
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// IntoColumn is the column that holds the target of a selection with an INTO
+// clause.
+const IntoColumn = "into"
+
+// Flatten converts a nested query result into rows. Each group in the result
+// becomes a row with a column per dimension followed by a column per
+// aggregate. Nested maps are treated as dimensions keyed by value except for
+// the results of selections with an INTO clause, whose targets are given in
+// into. Their rows have the target in the IntoColumn, which is the first
+// column. Columns that do not apply to a row are nil. Returns
+// ErrColumnConflict if a name is used for both a dimension and an aggregate.
+func Flatten(output map[string]interface{}, into ...string) (columns []string, values [][]interface{}, err error) {
+	var dimensions, aggregates []string
+	isDimension, isAggregate := map[string]bool{}, map[string]bool{}
+	var records []map[string]interface{}
+
+	var walk func(m map[string]interface{}, record map[string]interface{})
+	walk = func(m map[string]interface{}, record map[string]interface{}) {
+		// Aggregates at this level form a row with the parent dimensions.
+		row := map[string]interface{}{}
+		for k, v := range record {
+			row[k] = v
+		}
+		hasAggregate := false
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]interface{}); !ok {
+				if !isAggregate[k] {
+					isAggregate[k] = true
+					aggregates = append(aggregates, k)
+				}
+				row[k] = m[k]
+				hasAggregate = true
+			}
+		}
+		if hasAggregate {
+			records = append(records, row)
+		}
+
+		// Each value of a dimension is walked with the dimension added.
+		for _, k := range sortedKeys(m) {
+			groups, ok := m[k].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !isDimension[k] {
+				isDimension[k] = true
+				dimensions = append(dimensions, k)
+			}
+			for _, value := range sortedKeys(groups) {
+				child, _ := groups[value].(map[string]interface{})
+				dims := map[string]interface{}{k: value}
+				for k, v := range record {
+					dims[k] = v
+				}
+				walk(child, dims)
+			}
+		}
+	}
+
+	// Selections with an INTO clause write to a map at the top level which
+	// is walked as its own result.
+	targets := map[string]map[string]interface{}{}
+	for _, name := range into {
+		if m, ok := output[name].(map[string]interface{}); ok {
+			targets[name] = m
+		}
+	}
+	if len(targets) > 0 {
+		rest := map[string]interface{}{}
+		for k, v := range output {
+			if _, ok := targets[k]; !ok {
+				rest[k] = v
+			}
+		}
+		output = rest
+		isDimension[IntoColumn] = true
+		dimensions = append(dimensions, IntoColumn)
+	}
+	walk(output, map[string]interface{}{})
+	for _, name := range into {
+		if m, ok := targets[name]; ok {
+			delete(targets, name)
+			walk(m, map[string]interface{}{IntoColumn: name})
+		}
+	}
+	for _, k := range aggregates {
+		if isDimension[k] {
+			return nil, nil, ErrColumnConflict
+		}
+	}
+
+	sort.Strings(aggregates)
+	columns = append(dimensions, aggregates...)
+	for _, record := range records {
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = record[column]
+		}
+		values = append(values, row)
+	}
+	return columns, values, nil
+}
+
+// intoNames returns the INTO targets of a query's selections. Queries that
+// cannot be parsed are treated as having none.
+func intoNames(query string) []string {
+	q, err := skyql.Parse(query)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	var visit func(stmts []skyql.Statement)
+	visit = func(stmts []skyql.Statement) {
+		for _, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *skyql.Selection:
+				if stmt.Into != "" {
+					names = append(names, stmt.Into)
+				}
+			case *skyql.Condition:
+				visit(stmt.Statements)
+			case *skyql.SessionLoop:
+				visit(stmt.Statements)
+			}
+		}
+	}
+	visit(q.Statements)
+	return names
+}
+
+// rows holds a flattened query result.
+type rows struct {
+	columns []string
+	values  [][]interface{}
+	pos     int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	for i, v := range r.values[r.pos] {
+		dest[i] = v
+	}
+	r.pos++
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns FLOAT, BOOLEAN or TEXT depending on the
+// values in the column.
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	switch r.ColumnTypeScanType(index) {
+	case reflect.TypeOf(float64(0)):
+		return "FLOAT"
+	case reflect.TypeOf(false):
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+// ColumnTypeScanType returns the Go type of the column's values. Columns
+// with values of more than one type are scanned as interface{}.
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	var typ reflect.Type
+	for _, row := range r.values {
+		if row[index] == nil {
+			continue
+		} else if t := reflect.TypeOf(row[index]); typ == nil {
+			typ = t
+		} else if typ != t {
+			return reflect.TypeOf((*interface{})(nil)).Elem()
+		}
+	}
+	if typ == nil {
+		return reflect.TypeOf("")
+	}
+	return typ
+}
+
+// ColumnTypeNullable reports whether the column has any nil values.
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	for _, row := range r.values {
+		if row[index] == nil {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package skysql
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that DSNs are parsed with and without a scheme.
+func TestParseDSN(t *testing.T) {
+	host, table, err := ParseDSN("sky://example.com:8585/users")
+	assert.NoError(t, err)
+	assert.Equal(t, host, "example.com:8585")
+	assert.Equal(t, table, "users")
+
+	host, table, err = ParseDSN("/users")
+	assert.NoError(t, err)
+	assert.Equal(t, host, "localhost:8585")
+	assert.Equal(t, table, "users")
+
+	_, _, err = ParseDSN("localhost:8585")
+	assert.Equal(t, err, ErrTableRequired)
+}
+
+// Ensure that nested group by results are flattened into rows.
+func TestFlatten(t *testing.T) {
+	columns, values, err := Flatten(map[string]interface{}{
+		"count": float64(3),
+		"gender": map[string]interface{}{
+			"m": map[string]interface{}{"action": map[string]interface{}{
+				"home": map[string]interface{}{"count": float64(1), "total": float64(10)},
+			}},
+			"f": map[string]interface{}{"action": map[string]interface{}{
+				"cart": map[string]interface{}{"count": float64(2)},
+			}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, columns, []string{"gender", "action", "count", "total"})
+	assert.Equal(t, values, [][]interface{}{
+		{nil, nil, float64(3), nil},
+		{"f", "cart", float64(2), nil},
+		{"m", "home", float64(1), float64(10)},
+	})
+
+	// A name used for a dimension and an aggregate has no single column.
+	_, _, err = Flatten(map[string]interface{}{
+		"action": float64(3),
+		"gender": map[string]interface{}{
+			"f": map[string]interface{}{"action": map[string]interface{}{"cart": map[string]interface{}{"count": float64(2)}}},
+		},
+	})
+	assert.Equal(t, err, ErrColumnConflict)
+}
+
+// Ensure that the results of selections with an INTO clause are not treated
+// as dimensions.
+func TestFlattenInto(t *testing.T) {
+	output := map[string]interface{}{
+		"count": float64(3),
+		"x": map[string]interface{}{
+			"gender": map[string]interface{}{
+				"f": map[string]interface{}{"count": float64(2)},
+			},
+		},
+		"y": map[string]interface{}{"total": float64(10)},
+	}
+	assert.Equal(t, intoNames(`SELECT count() SELECT count() GROUP BY gender INTO "x" WHEN true THEN SELECT sum(price) AS total INTO "y" END`), []string{"x", "y"})
+	columns, values, err := Flatten(output, "x", "y")
+	assert.NoError(t, err)
+	assert.Equal(t, columns, []string{"into", "gender", "count", "total"})
+	assert.Equal(t, values, [][]interface{}{
+		{nil, nil, float64(3), nil},
+		{"x", "f", float64(2), nil},
+		{"y", nil, nil, float64(10)},
+	})
+}
+
+// Ensure that columns with mixed types are scanned as interface{}.
+func TestRowsColumnTypeScanType(t *testing.T) {
+	r := &rows{columns: []string{"a", "b", "c"}, values: [][]interface{}{
+		{"x", float64(1), nil},
+		{float64(2), float64(3), nil},
+	}}
+	assert.Equal(t, r.ColumnTypeScanType(0), reflect.TypeOf((*interface{})(nil)).Elem())
+	assert.Equal(t, r.ColumnTypeDatabaseTypeName(0), "TEXT")
+	assert.Equal(t, r.ColumnTypeScanType(1), reflect.TypeOf(float64(0)))
+	assert.Equal(t, r.ColumnTypeScanType(2), reflect.TypeOf(""))
+}
+
+// Ensure that pings fail with the context's error when it is done.
+func TestDriverPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	db, err := sql.Open("sky", strings.TrimPrefix(server.URL, "http://")+"/users")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, db.PingContext(ctx), context.DeadlineExceeded)
+}
+
+// Ensure that queries can be run through database/sql.
+func TestDriverQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, r.URL.Path, "/tables/users/query")
+		assert.Equal(t, string(b), "SELECT count() GROUP BY gender")
+		w.Write([]byte(`{"gender":{"f":{"count":2},"m":{"count":1}}}`))
+	}))
+	defer server.Close()
+
+	db, err := sql.Open("sky", strings.TrimPrefix(server.URL, "http://")+"/users")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT count() GROUP BY gender")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, types[0].DatabaseTypeName(), "TEXT")
+	assert.Equal(t, types[1].DatabaseTypeName(), "FLOAT")
+	assert.Equal(t, types[1].ScanType(), reflect.TypeOf(float64(0)))
+
+	var genders []string
+	var counts []int
+	for rows.Next() {
+		var gender string
+		var count int
+		assert.NoError(t, rows.Scan(&gender, &count))
+		genders, counts = append(genders, gender), append(counts, count)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, genders, []string{"f", "m"})
+	assert.Equal(t, counts, []int{2, 1})
+
+	_, err = db.Query("SELECT count()", 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package sky
+
+import (
+	"sort"
+	"time"
+)
+
+// ObjectState computes the effective state of an object from its events.
+// Permanent property values carry forward to later events while transient
+// values only apply to the event they are set on. Values for properties that
+// are not in the schema are treated as permanent.
+type ObjectState struct {
+	events     []*Event
+	properties map[string]*Property
+}
+
+// NewObjectState creates the state for an object from its events and the
+// properties of its table. The events do not need to be sorted.
+func NewObjectState(events []*Event, properties []*Property) *ObjectState {
+	s := &ObjectState{
+		events:     make([]*Event, len(events)),
+		properties: make(map[string]*Property),
+	}
+	copy(s.events, events)
+	sort.SliceStable(s.events, func(i, j int) bool { return s.events[i].Timestamp.Before(s.events[j].Timestamp) })
+	for _, p := range properties {
+		s.properties[p.Name] = p
+	}
+	return s
+}
+
+// At returns the state of the object at the given time. This includes all
+// permanent values set at or before the time and transient values set on an
+// event exactly at the time.
+func (s *ObjectState) At(timestamp time.Time) map[string]interface{} {
+	state := map[string]interface{}{}
+	for _, e := range s.events {
+		if e.Timestamp.After(timestamp) {
+			break
+		}
+		exact := e.Timestamp.Equal(timestamp)
+		for k, v := range e.Data {
+			if exact || !s.transient(k) {
+				state[k] = v
+			}
+		}
+	}
+	return state
+}
+
+// Each calls fn with the state of the object at each of its timestamps, in
+// order. Events with the same timestamp are merged into a single state.
+func (s *ObjectState) Each(fn func(timestamp time.Time, state map[string]interface{})) {
+	permanent := map[string]interface{}{}
+	for i := 0; i < len(s.events); {
+		timestamp := s.events[i].Timestamp
+		state := map[string]interface{}{}
+		for k, v := range permanent {
+			state[k] = v
+		}
+		for ; i < len(s.events) && s.events[i].Timestamp.Equal(timestamp); i++ {
+			for k, v := range s.events[i].Data {
+				state[k] = v
+				if !s.transient(k) {
+					permanent[k] = v
+				}
+			}
+		}
+		fn(timestamp, state)
+	}
+}
+
+// transient returns true if the named property is transient.
+func (s *ObjectState) transient(name string) bool {
+	p := s.properties[name]
+	return p != nil && p.Transient
+}
+
+// StateAt retrieves the events for an object and returns its effective state
+// at the given time.
+func (t *Table) StateAt(id string, timestamp time.Time) (map[string]interface{}, error) {
+	events, err := t.Events(id)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := t.Schema()
+	if err != nil {
+		return nil, err
+	}
+	return NewObjectState(events, properties).At(timestamp), nil
+}
@@ -0,0 +1,67 @@
+package sky
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that permanent values carry forward and transient values do not.
+func TestObjectStateAt(t *testing.T) {
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	t1, _ := ParseTimestamp("1970-01-01T00:00:01Z")
+	t2, _ := ParseTimestamp("1970-01-01T00:00:02Z")
+	t3, _ := ParseTimestamp("1970-01-01T00:00:03Z")
+	properties := []*Property{
+		{Name: "name", Transient: false, DataType: String},
+		{Name: "action", Transient: true, DataType: Factor},
+	}
+	events := []*Event{
+		{t2, map[string]interface{}{"name": "bob", "action": "A2"}},
+		{t0, map[string]interface{}{"name": "susy", "action": "A0"}},
+		{t1, map[string]interface{}{"action": "A1"}},
+	}
+	s := NewObjectState(events, properties)
+
+	assert.Equal(t, s.At(t0), map[string]interface{}{"name": "susy", "action": "A0"})
+	assert.Equal(t, s.At(t1), map[string]interface{}{"name": "susy", "action": "A1"})
+	assert.Equal(t, s.At(t1.Add(1)), map[string]interface{}{"name": "susy"})
+	assert.Equal(t, s.At(t2), map[string]interface{}{"name": "bob", "action": "A2"})
+	assert.Equal(t, s.At(t3), map[string]interface{}{"name": "bob"})
+	assert.Equal(t, s.At(t0.Add(-1)), map[string]interface{}{})
+}
+
+// Ensure that each timestamp is visited in order with events on the same
+// timestamp merged.
+func TestObjectStateEach(t *testing.T) {
+	t0, _ := ParseTimestamp("1970-01-01T00:00:00Z")
+	t1, _ := ParseTimestamp("1970-01-01T00:00:01Z")
+	t2, _ := ParseTimestamp("1970-01-01T00:00:02Z")
+	properties := []*Property{
+		{Name: "name", Transient: false, DataType: String},
+		{Name: "action", Transient: true, DataType: Factor},
+	}
+	events := []*Event{
+		{t2, map[string]interface{}{"action": "A2"}},
+		{t0, map[string]interface{}{"name": "susy", "action": "A0"}},
+		{t1, map[string]interface{}{"action": "A1"}},
+		{t1, map[string]interface{}{"name": "bob"}},
+	}
+
+	var timestamps []string
+	var states []map[string]interface{}
+	NewObjectState(events, properties).Each(func(timestamp time.Time, state map[string]interface{}) {
+		timestamps = append(timestamps, FormatTimestamp(timestamp))
+		states = append(states, state)
+	})
+	assert.Equal(t, timestamps, []string{"1970-01-01T00:00:00Z", "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z"})
+	assert.Equal(t, states, []map[string]interface{}{
+		{"name": "susy", "action": "A0"},
+		{"name": "bob", "action": "A1"},
+		{"name": "bob", "action": "A2"},
+	})
+
+	// An object without events is never visited.
+	NewObjectState(nil, properties).Each(func(time.Time, map[string]interface{}) { t.Fatal("unexpected state") })
+}
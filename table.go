@@ -1,9 +1,12 @@
 package sky
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 )
 
@@ -26,11 +29,22 @@ func (t *Table) Property(name string) (*Property, error) {
 	return property, nil
 }
 
-// Properties retrieves a list of all properties on the table.
+// Properties retrieves a list of all properties on the table. Servers that
+// support paging are read one page at a time and the properties are returned
+// in name order. Otherwise they are returned in the server's order.
 func (t *Table) Properties() ([]*Property, error) {
 	if t.Client == nil {
 		return nil, ErrClientRequired
 	}
+	if t.Client.supports(CapabilityPaging) {
+		properties := []*Property{}
+		i := t.PropertyIterator("")
+		for i.Next() {
+			properties = append(properties, i.Property())
+		}
+		return properties, i.Err()
+	}
+
 	properties := []*Property{}
 	if err := t.Client.Send("GET", fmt.Sprintf("/tables/%s/properties", t.Name), nil, &properties); err != nil {
 		return nil, err
@@ -45,6 +59,8 @@ func (t *Table) CreateProperty(property *Property) error {
 	} else if property == nil {
 		return ErrPropertyRequired
 	}
+	defer t.Client.clearListCache()
+	defer t.invalidateSchema()
 	return t.Client.Send("POST", fmt.Sprintf("/tables/%s/properties", t.Name), property, property)
 }
 
@@ -55,6 +71,8 @@ func (t *Table) RenameProperty(oldName string, newName string) error {
 	} else if oldName == "" || newName == "" {
 		return ErrPropertyNameRequired
 	}
+	defer t.Client.clearListCache()
+	defer t.invalidateSchema()
 	return t.Client.Send("PATCH", fmt.Sprintf("/tables/%s/properties/%s", t.Name, oldName), &Property{Name: newName}, nil)
 }
 
@@ -65,9 +83,23 @@ func (t *Table) DeleteProperty(name string) error {
 	} else if name == "" {
 		return ErrPropertyNameRequired
 	}
+	defer t.Client.clearListCache()
+	defer t.invalidateSchema()
 	return t.Client.Send("DELETE", fmt.Sprintf("/tables/%s/properties/%s", t.Name, name), nil, nil)
 }
 
+// Keys retrieves the identifiers of all objects in the table.
+func (t *Table) Keys() ([]string, error) {
+	if t.Client == nil {
+		return nil, ErrClientRequired
+	}
+	keys := []string{}
+	if err := t.Client.Send("GET", fmt.Sprintf("/tables/%s/keys", t.Name), nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
 // Event retrieves a single event for an object at a given time.
 func (t *Table) Event(id string, timestamp time.Time) (*Event, error) {
 	if t.Client == nil {
@@ -77,7 +109,7 @@ func (t *Table) Event(id string, timestamp time.Time) (*Event, error) {
 	}
 
 	e := map[string]interface{}{}
-	if err := t.Client.Send("GET", fmt.Sprintf("/tables/%s/objects/%s/events/%s", t.Name, id, FormatTimestamp(timestamp)), nil, &e); err != nil {
+	if err := t.getEvents(fmt.Sprintf("/tables/%s/objects/%s/events/%s", t.Name, id, FormatTimestamp(timestamp)), nil, &e); err != nil {
 		return nil, err
 	} else if len(e) == 0 {
 		return nil, nil
@@ -88,6 +120,9 @@ func (t *Table) Event(id string, timestamp time.Time) (*Event, error) {
 	if err := event.Deserialize(e); err != nil {
 		return nil, err
 	}
+	if err := t.convertEvents([]*Event{event}); err != nil {
+		return nil, err
+	}
 	return event, nil
 }
 
@@ -100,7 +135,7 @@ func (t *Table) Events(id string) ([]*Event, error) {
 	}
 
 	output := make([]map[string]interface{}, 0)
-	if err := t.Client.Send("GET", fmt.Sprintf("/tables/%s/objects/%s/events", t.Name, id), nil, &output); err != nil {
+	if err := t.getEvents(fmt.Sprintf("/tables/%s/objects/%s/events", t.Name, id), nil, &output); err != nil {
 		return nil, err
 	}
 
@@ -111,11 +146,109 @@ func (t *Table) Events(id string) ([]*Event, error) {
 		event.Deserialize(i)
 		events = append(events, event)
 	}
+	if err := t.convertEvents(events); err != nil {
+		return nil, err
+	}
 	return events, nil
 }
 
-// InsertEvent adds an event to an object.
+// EventsBetween retrieves the events for an object with timestamps at or after
+// start and before end. A zero start or end leaves that side of the range
+// open. The range is filtered by the server if it supports event ranges and by
+// the client otherwise.
+func (t *Table) EventsBetween(id string, start, end time.Time) ([]*Event, error) {
+	if t.Client == nil {
+		return nil, ErrClientRequired
+	} else if id == "" {
+		return nil, ErrIDRequired
+	}
+
+	var query url.Values
+	if t.Client.supports(CapabilityEventRange) {
+		query = url.Values{}
+		if !start.IsZero() {
+			query.Set("start", FormatTimestamp(start))
+		}
+		if !end.IsZero() {
+			query.Set("end", FormatTimestamp(end))
+		}
+	}
+	output := make([]map[string]interface{}, 0)
+	if err := t.getEvents(fmt.Sprintf("/tables/%s/objects/%s/events", t.Name, id), query, &output); err != nil {
+		return nil, err
+	}
+
+	events := []*Event{}
+	for _, i := range output {
+		event := &Event{}
+		event.Deserialize(i)
+		if !event.Timestamp.Before(start) && (end.IsZero() || event.Timestamp.Before(end)) {
+			events = append(events, event)
+		}
+	}
+	if err := t.convertEvents(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// getEvents retrieves events from the server. If the client has typed events
+// enabled then numbers are decoded as json.Number so that they can be
+// converted to their property's type without losing precision.
+func (t *Table) getEvents(path string, query url.Values, ret interface{}) error {
+	if !t.Client.TypedEvents {
+		return t.Client.sendQuery(context.Background(), "GET", path, query, nil, ret)
+	}
+	var body json.RawMessage
+	if err := t.Client.sendQuery(context.Background(), "GET", path, query, nil, &body); err != nil {
+		return err
+	} else if len(body) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return decoder.Decode(ret)
+}
+
+// convertEvents converts event data to property types if the client has
+// typed events enabled.
+func (t *Table) convertEvents(events []*Event) error {
+	if !t.Client.TypedEvents || len(events) == 0 {
+		return nil
+	}
+	properties, err := t.Schema()
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		e.Convert(properties)
+	}
+	return nil
+}
+
+// InsertEvent adds an event to an object. If the server rejects the event
+// then it is also sent to the client's dead letter sink. If the client has a
+// deduper then exact duplicates of recently inserted events are skipped.
 func (t *Table) InsertEvent(id string, e *Event) error {
+	return t.InsertEventContext(context.Background(), id, e)
+}
+
+// InsertEventContext adds an event to an object. If the client has a tracer
+// then the insert's span is started as a child of the context.
+func (t *Table) InsertEventContext(ctx context.Context, id string, e *Event) error {
+	if t.Client != nil && t.Client.Deduper.Duplicate(t.Name, id, e) {
+		return nil
+	}
+	if err := t.insertEvent(ctx, id, e, true); err != nil {
+		return err
+	}
+	t.Client.Deduper.Remember(t.Name, id, e)
+	return nil
+}
+
+// insertEvent sends an event to the server without deduplication. Rejected
+// events are sent to the dead letter sink if deadLetter is true.
+func (t *Table) insertEvent(ctx context.Context, id string, e *Event, deadLetter bool) error {
 	if t.Client == nil {
 		return ErrClientRequired
 	} else if id == "" {
@@ -123,7 +256,14 @@ func (t *Table) InsertEvent(id string, e *Event) error {
 	} else if e == nil {
 		return ErrEventRequired
 	}
-	return t.Client.Send("PATCH", fmt.Sprintf("/tables/%s/objects/%s/events/%s", t.Name, id, FormatTimestamp(e.Timestamp)), e.Serialize(), nil)
+	ctx, span := t.Client.startSpan(ctx, "sky.insert_event", Attribute{"sky.table", t.Name}, Attribute{"sky.object_id", id})
+	err := t.Client.SendContext(ctx, "PATCH", fmt.Sprintf("/tables/%s/objects/%s/events/%s", t.Name, id, FormatTimestamp(e.Timestamp)), e.Serialize(), nil)
+	span.End(err)
+	t.refreshSchemaOn(err)
+	if err, ok := rejection(err); ok && deadLetter {
+		t.Client.deadLetter(t.Name, id, e, err)
+	}
+	return err
 }
 
 // DeleteEvent deletes an event on an object at the given time.
@@ -151,6 +291,12 @@ func (t *Table) Stream() (*TableEventStream, error) {
 	return NewTableEventStream(t.Client, t)
 }
 
+// StreamContext returns a new stream for the table. If the client has a
+// tracer then the stream's span is started as a child of the context.
+func (t *Table) StreamContext(ctx context.Context) (*TableEventStream, error) {
+	return NewTableEventStreamContext(ctx, t.Client, t)
+}
+
 // Stats retrieves basic statistics on the table.
 func (t *Table) Stats() (*Stats, error) {
 	if t.Client == nil {
@@ -163,16 +309,41 @@ func (t *Table) Stats() (*Stats, error) {
 	return output, nil
 }
 
-// Query executes a SkyQL query on the table and returns the result.
+// Query executes a SkyQL query on the table and returns the result. If the
+// client has a query validator then the query is checked before it is sent.
 func (t *Table) Query(q string) (map[string]interface{}, error) {
+	return t.QueryContext(context.Background(), q)
+}
+
+// QueryContext executes a SkyQL query on the table and returns the result.
+// If the client has a tracer then the query's span is started as a child of
+// the context.
+func (t *Table) QueryContext(ctx context.Context, q string) (output map[string]interface{}, err error) {
 	if t.Client == nil {
 		return nil, ErrClientRequired
 	}
 	if q == "" {
 		return nil, ErrQueryRequired
 	}
-	output := map[string]interface{}{}
-	if err := t.Client.Send("POST", fmt.Sprintf("/tables/%s/query", t.Name), q, &output); err != nil {
+	if t.Client.QueryValidator != nil {
+		properties, err := t.Schema()
+		if err != nil {
+			return nil, err
+		}
+		factorValues, err := t.FactorValues()
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Client.QueryValidator(q, properties, factorValues); err != nil {
+			return nil, err
+		}
+	}
+	ctx, span := t.Client.startSpan(ctx, "sky.query", Attribute{"sky.table", t.Name}, Attribute{"sky.query.hash", queryHash(q)})
+	defer func() { span.End(err) }()
+
+	output = map[string]interface{}{}
+	if err := t.Client.SendContext(ctx, "POST", fmt.Sprintf("/tables/%s/query", t.Name), q, &output); err != nil {
+		t.refreshSchemaOn(err)
 		return nil, err
 	}
 	return output, nil
@@ -1,9 +1,26 @@
 package sky
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// TimestampPrecision is the resolution at which the server stores timestamps.
+const TimestampPrecision = time.Microsecond
+
+// DefaultTimestampLayouts are the layouts tried by a TimestampParser with no
+// layouts set. Layouts without a zone are parsed in the parser's location.
+var DefaultTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
 // ParseTimestamp parses an ISO8601 timestamp with or without fractional seconds.
 func ParseTimestamp(str string) (time.Time, error) {
 	if timestamp, err := time.Parse(time.RFC3339Nano, str); err == nil {
@@ -16,3 +33,128 @@ func ParseTimestamp(str string) (time.Time, error) {
 func FormatTimestamp(timestamp time.Time) string {
 	return timestamp.UTC().Format(time.RFC3339Nano)
 }
+
+// TimestampParser parses timestamps from sources that use a variety of
+// layouts and numeric epochs.
+type TimestampParser struct {
+	// Layouts are tried in order. DefaultTimestampLayouts are used if blank.
+	Layouts []string
+
+	// EpochUnit is the unit of numeric timestamps, such as time.Second or
+	// time.Millisecond. If zero then the unit is guessed from the magnitude
+	// of the number.
+	EpochUnit time.Duration
+
+	// Location is used for layouts without a zone. Defaults to UTC.
+	Location *time.Location
+
+	// Precision is the resolution that parsed timestamps are truncated to.
+	// Defaults to TimestampPrecision.
+	Precision time.Duration
+}
+
+// Parse parses a timestamp string. Strings that are numbers are parsed as
+// epochs and all other strings are matched against the parser's layouts.
+func (p *TimestampParser) Parse(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		return p.ParseEpoch(f)
+	}
+
+	layouts := p.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultTimestampLayouts
+	}
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, str, loc); err == nil {
+			return p.truncate(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %s", str)
+}
+
+// ParseValue parses a timestamp from a decoded JSON value or Go value. Strings
+// are parsed with Parse, numbers are parsed as epochs and times are truncated
+// to the parser's precision.
+func (p *TimestampParser) ParseValue(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case string:
+		return p.Parse(v)
+	case time.Time:
+		return p.truncate(v), nil
+	case float64:
+		return p.ParseEpoch(v)
+	case int64:
+		return p.parseEpochInt(v)
+	case int:
+		return p.parseEpochInt(int64(v))
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %v", v)
+}
+
+// ParseEpoch converts a number in the parser's epoch unit to a time.
+func (p *TimestampParser) ParseEpoch(f float64) (time.Time, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", f)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return p.parseEpochInt(int64(f))
+	}
+	unit := p.epochUnit(f)
+	sec, frac := math.Modf(f * float64(unit) / float64(time.Second))
+	return p.truncate(time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC()), nil
+}
+
+// parseEpochInt converts an integer in the parser's epoch unit to a time
+// without the rounding of floating point math.
+func (p *TimestampParser) parseEpochInt(n int64) (time.Time, error) {
+	unit := int64(p.epochUnit(float64(n)))
+	second := int64(time.Second)
+	if unit <= second && second%unit == 0 {
+		perSecond := second / unit
+		return p.truncate(time.Unix(n/perSecond, (n%perSecond)*unit).UTC()), nil
+	}
+
+	// Split n*unit into whole seconds and the remaining nanoseconds.
+	q, r := unit/second, unit%second
+	if overflows(n, q) || overflows(n, r) {
+		return time.Time{}, fmt.Errorf("timestamp out of range: %d", n)
+	}
+	return p.truncate(time.Unix(n*q+(n*r)/second, (n*r)%second).UTC()), nil
+}
+
+// overflows returns true if n*m does not fit in an int64. m must not be
+// negative.
+func overflows(n, m int64) bool {
+	return m != 0 && (n > math.MaxInt64/m || n < math.MinInt64/m)
+}
+
+// epochUnit returns the parser's epoch unit or guesses the unit of a number.
+// Numbers are treated as seconds up to the year 5138, then milliseconds,
+// microseconds and nanoseconds.
+func (p *TimestampParser) epochUnit(f float64) time.Duration {
+	if p.EpochUnit > 0 {
+		return p.EpochUnit
+	}
+	switch f = math.Abs(f); {
+	case f < 1e11:
+		return time.Second
+	case f < 1e14:
+		return time.Millisecond
+	case f < 1e17:
+		return time.Microsecond
+	}
+	return time.Nanosecond
+}
+
+func (p *TimestampParser) truncate(t time.Time) time.Time {
+	precision := p.Precision
+	if precision == 0 {
+		precision = TimestampPrecision
+	}
+	return t.Truncate(precision)
+}
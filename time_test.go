@@ -0,0 +1,94 @@
+package sky
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that formatted timestamps keep their full precision.
+func TestFormatTimestamp(t *testing.T) {
+	timestamp := time.Date(2013, 11, 12, 0, 0, 0, 123456789, time.UTC)
+	assert.Equal(t, FormatTimestamp(timestamp), "2013-11-12T00:00:00.123456789Z")
+}
+
+// Ensure that timestamps can be parsed from several layouts and time zones.
+func TestTimestampParserLayouts(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	p := &TimestampParser{Location: loc}
+	expected := time.Date(2013, 11, 12, 5, 30, 0, 0, time.UTC)
+
+	for _, str := range []string{
+		"2013-11-12T05:30:00Z",
+		"2013-11-12T00:30:00-05:00",
+		"2013-11-12T00:30:00",
+		"2013-11-12 00:30:00",
+	} {
+		timestamp, err := p.Parse(str)
+		assert.NoError(t, err, str)
+		assert.True(t, timestamp.Equal(expected), str)
+	}
+
+	_, err := p.Parse("11/12/2013")
+	assert.Error(t, err)
+
+	p.Layouts = []string{"01/02/2006"}
+	timestamp, err := p.Parse("11/12/2013")
+	assert.NoError(t, err)
+	assert.True(t, timestamp.Equal(time.Date(2013, 11, 12, 0, 0, 0, 0, loc)))
+}
+
+// Ensure that numeric epochs are parsed in the configured or guessed unit.
+func TestTimestampParserEpoch(t *testing.T) {
+	expected := time.Date(2013, 11, 12, 0, 0, 0, 500000000, time.UTC)
+	p := &TimestampParser{}
+	for _, v := range []interface{}{"1384214400.5", 1384214400.5, int64(1384214400500), "1384214400500000", 1384214400500000000} {
+		timestamp, err := p.ParseValue(v)
+		assert.NoError(t, err)
+		assert.Equal(t, timestamp, expected)
+	}
+
+	p.EpochUnit = time.Millisecond
+	timestamp, err := p.Parse("1500")
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp, time.Unix(1, 500000000).UTC())
+
+	_, err = p.ParseValue(true)
+	assert.Error(t, err)
+}
+
+// Ensure that epochs in units longer than a second are parsed.
+func TestTimestampParserEpochLongUnits(t *testing.T) {
+	p := &TimestampParser{EpochUnit: time.Minute}
+	timestamp, err := p.ParseEpoch(5)
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp, time.Unix(300, 0).UTC())
+
+	timestamp, err = p.ParseValue(-2)
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp, time.Unix(-120, 0).UTC())
+
+	p.EpochUnit = 1500 * time.Millisecond
+	timestamp, err = p.ParseValue(int64(3))
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp, time.Unix(4, 500000000).UTC())
+
+	p.EpochUnit = 24 * time.Hour
+	_, err = p.ParseValue(int64(math.MaxInt64 / 1000))
+	assert.Error(t, err)
+}
+
+// Ensure that parsed timestamps are truncated to the parser's precision.
+func TestTimestampParserPrecision(t *testing.T) {
+	p := &TimestampParser{}
+	timestamp, err := p.Parse("2013-11-12T00:00:00.123456789Z")
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp.Nanosecond(), 123456000)
+
+	p.Precision = time.Second
+	timestamp, err = p.Parse("2013-11-12T00:00:00.123456789Z")
+	assert.NoError(t, err)
+	assert.Equal(t, timestamp.Nanosecond(), 0)
+}
@@ -0,0 +1,57 @@
+package sky
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// TraceParentHeader is the W3C trace context header injected into requests.
+const TraceParentHeader = "traceparent"
+
+// Tracer starts spans for client operations. Implementations are expected to
+// read the parent span from the context and return a context holding the new
+// span.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a traced operation.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...Attribute)
+
+	// TraceParent returns the W3C traceparent header value for the span. If
+	// it is blank then no header is injected.
+	TraceParent() string
+
+	// End finishes the span. err is nil if the operation succeeded.
+	End(err error)
+}
+
+// Attribute is a key/value pair attached to a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// startSpan starts a span if the client has a tracer.
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return c.Tracer.Start(ctx, name, attrs...)
+}
+
+// noopSpan is used when the client does not have a tracer.
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) TraceParent() string        { return "" }
+func (noopSpan) End(error)                  {}
+
+// queryHash returns a short hash identifying the text of a query.
+func queryHash(q string) string {
+	h := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,131 @@
+package sky
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTracer records the spans it starts.
+type testTracer struct {
+	spans []*testSpan
+}
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	ended  bool
+}
+
+type testSpanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attrs: map[string]interface{}{}}
+	span.SetAttributes(attrs...)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *testSpan) TraceParent() string {
+	return fmt.Sprintf("00-%032x-%016x-01", 1, len(s.name))
+}
+
+func (s *testSpan) End(err error) { s.ended = true }
+
+// Ensure that queries start a span with a child span for the request and that
+// the request carries the trace context.
+func TestClientTracerQuery(t *testing.T) {
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"count":1}`))
+	}))
+	defer server.Close()
+
+	tracer := &testTracer{}
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), Tracer: tracer}
+	_, err := (&Table{Client: c, Name: "t0"}).Query("SELECT count()")
+	assert.NoError(t, err)
+	if assert.Equal(t, len(tracer.spans), 2) {
+		query, send := tracer.spans[0], tracer.spans[1]
+		assert.Equal(t, query.name, "sky.query")
+		assert.Equal(t, query.attrs["sky.table"], "t0")
+		assert.Equal(t, query.attrs["sky.query.hash"], queryHash("SELECT count()"))
+		assert.Equal(t, send.name, "sky.send")
+		assert.Equal(t, send.parent, query)
+		assert.Equal(t, send.attrs["http.status_code"], 200)
+		assert.True(t, query.ended && send.ended)
+		assert.Equal(t, traceParent, send.TraceParent())
+	}
+}
+
+// Ensure that the stream request header carries the trace context.
+func TestClientTracerStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	headers := make(chan http.Header, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err == nil {
+			headers <- req.Header
+		}
+	}()
+
+	tracer := &testTracer{}
+	c := &Client{Host: ln.Addr().String(), Tracer: tracer}
+	stream, err := (&Table{Client: c, Name: "t0"}).Stream()
+	assert.NoError(t, err)
+	assert.Equal(t, (<-headers).Get("traceparent"), tracer.spans[0].TraceParent())
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, tracer.spans[0].name, "sky.stream")
+	assert.Equal(t, tracer.spans[1].name, "sky.stream.flush")
+	assert.Equal(t, tracer.spans[1].parent, tracer.spans[0])
+	assert.True(t, tracer.spans[0].ended)
+}
+
+// Ensure that inserts are traced as children of the caller's span and that a
+// stream's span is ended when it fails to connect.
+func TestClientTracerInsertEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tracer := &testTracer{}
+	c := &Client{Host: strings.TrimPrefix(server.URL, "http://"), Tracer: tracer}
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	assert.NoError(t, (&Table{Client: c, Name: "t0"}).InsertEventContext(ctx, "o0", &Event{Data: map[string]interface{}{}}))
+	if assert.Equal(t, len(tracer.spans), 3) {
+		assert.Equal(t, tracer.spans[1].name, "sky.insert_event")
+		assert.Equal(t, tracer.spans[1].parent, parent)
+	}
+
+	server.Close()
+	tracer.spans = nil
+	_, err := (&Table{Client: c, Name: "t0"}).Stream()
+	assert.Error(t, err)
+	if assert.Equal(t, len(tracer.spans), 1) {
+		assert.True(t, tracer.spans[0].ended)
+	}
+}